@@ -0,0 +1,330 @@
+package backends
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type accuConfig struct {
+	apiKey string
+	lang   string
+	debug  bool
+
+	// locations caches the results of locationKey, locationsMu guards it
+	// as Fetch is called concurrently for several locations
+	locations   map[string]*accuLocation
+	locationsMu sync.Mutex
+}
+
+type accuValue struct {
+	Value *float32 `json:"Value"`
+	Unit  string   `json:"Unit"`
+}
+
+type accuLocation struct {
+	Key           string `json:"Key"`
+	LocalizedName string `json:"LocalizedName"`
+	Country       struct {
+		LocalizedName string `json:"LocalizedName"`
+	} `json:"Country"`
+	GeoPosition struct {
+		Latitude  *float32 `json:"Latitude"`
+		Longitude *float32 `json:"Longitude"`
+	} `json:"GeoPosition"`
+	TimeZone struct {
+		Name string `json:"Name"`
+	} `json:"TimeZone"`
+}
+
+type accuHour struct {
+	EpochDateTime       *int64    `json:"EpochDateTime"`
+	WeatherIcon         int       `json:"WeatherIcon"`
+	IconPhrase          string    `json:"IconPhrase"`
+	Temperature         accuValue `json:"Temperature"`
+	RealFeelTemperature accuValue `json:"RealFeelTemperature"`
+	Wind                struct {
+		Speed     accuValue `json:"Speed"`
+		Direction struct {
+			Degrees *int `json:"Degrees"`
+		} `json:"Direction"`
+	} `json:"Wind"`
+	WindGust struct {
+		Speed accuValue `json:"Speed"`
+	} `json:"WindGust"`
+	RelativeHumidity         *int      `json:"RelativeHumidity"`
+	Visibility               accuValue `json:"Visibility"`
+	PrecipitationProbability *int      `json:"PrecipitationProbability"`
+	TotalLiquid              accuValue `json:"TotalLiquid"`
+}
+
+const (
+	// see https://developer.accuweather.com/apis
+	accuLuri = "https://dataservice.accuweather.com/locations/v1/cities/geoposition/search?apikey=%s&q=%s&language=%s"
+	accuWuri = "https://dataservice.accuweather.com/forecasts/v1/hourly/12hour/%s?apikey=%s&details=true&metric=false&language=%s"
+)
+
+// accuMetric converts an imperial accuweather value into the given metric
+// unit. Values already in the metric unit are passed through unchanged.
+func accuMetric(v accuValue, unit string) *float32 {
+	if v.Value == nil {
+		return nil
+	}
+	ret := *v.Value
+	if v.Unit == unit {
+		return &ret
+	}
+	switch v.Unit {
+	case "F":
+		ret = (ret - 32) / 1.8
+	case "mi/h":
+		ret *= 1.609
+	case "mi":
+		ret *= 1609.344
+	case "in":
+		ret *= 0.0254
+	case "mm":
+		ret /= 1000
+	case "km":
+		ret *= 1000
+	}
+	return &ret
+}
+
+// locationKey resolves a latitude,longitude pair to the accuweather location.
+// Results are remembered, so every location is only looked up once.
+func (c *accuConfig) locationKey(ctx context.Context, location string) (*accuLocation, error) {
+	c.locationsMu.Lock()
+	loc, ok := c.locations[location]
+	c.locationsMu.Unlock()
+	if ok {
+		return loc, nil
+	}
+
+	loc = new(accuLocation)
+	if err := iface.FetchJSON(ctx, fmt.Sprintf(accuLuri, c.apiKey, location, c.lang), nil, loc); err != nil {
+		return nil, err
+	}
+	if loc.Key == "" {
+		return nil, fmt.Errorf("No accuweather location found for %s", location)
+	}
+
+	c.locationsMu.Lock()
+	defer c.locationsMu.Unlock()
+	if c.locations == nil {
+		c.locations = make(map[string]*accuLocation)
+	}
+	c.locations[location] = loc
+	return loc, nil
+}
+
+// accuCodemap maps the accuweather weather icons to weather codes.
+var accuCodemap = map[int]iface.WeatherCode{
+	1:  iface.CodeSunny,
+	2:  iface.CodeSunny,
+	3:  iface.CodePartlyCloudy,
+	4:  iface.CodePartlyCloudy,
+	5:  iface.CodeFog,
+	6:  iface.CodeCloudy,
+	7:  iface.CodeCloudy,
+	8:  iface.CodeVeryCloudy,
+	11: iface.CodeFog,
+	12: iface.CodeLightRain,
+	13: iface.CodeLightShowers,
+	14: iface.CodeLightShowers,
+	15: iface.CodeThunderyHeavyRain,
+	16: iface.CodeThunderyShowers,
+	17: iface.CodeThunderyShowers,
+	18: iface.CodeHeavyRain,
+	19: iface.CodeLightSnow,
+	20: iface.CodeLightSnowShowers,
+	21: iface.CodeLightSnowShowers,
+	22: iface.CodeHeavySnow,
+	23: iface.CodeHeavySnowShowers,
+	24: iface.CodeLightSleet,
+	25: iface.CodeLightSleet,
+	26: iface.CodeLightSleet,
+	29: iface.CodeLightSleetShowers,
+	30: iface.CodeSunny,
+	31: iface.CodeSunny,
+	32: iface.CodePartlyCloudy,
+	33: iface.CodeSunny,
+	34: iface.CodeSunny,
+	35: iface.CodePartlyCloudy,
+	36: iface.CodePartlyCloudy,
+	37: iface.CodeFog,
+	38: iface.CodeCloudy,
+	39: iface.CodeLightShowers,
+	40: iface.CodeLightRain,
+	41: iface.CodeThunderyShowers,
+	42: iface.CodeThunderyShowers,
+	43: iface.CodeLightSnow,
+	44: iface.CodeHeavySnow,
+}
+
+// CodeMap returns the accuweather weather icons and the weather codes they map to.
+func (c *accuConfig) CodeMap() map[string]iface.WeatherCode {
+	return iface.IntCodeMap(accuCodemap)
+}
+
+func (c *accuConfig) parseCond(hour accuHour, tz *time.Location) (ret iface.Cond, err error) {
+	if hour.EpochDateTime == nil {
+		return iface.Cond{}, fmt.Errorf("The accuweather response did not provide a time for the weather condition")
+	}
+	ret.Time = time.Unix(*hour.EpochDateTime, 0).In(tz)
+
+	ret.Code = iface.CodeUnknown
+	if val, ok := accuCodemap[hour.WeatherIcon]; ok {
+		ret.Code = val
+	}
+	ret.Desc = hour.IconPhrase
+
+	ret.TempC = accuMetric(hour.Temperature, "C")
+	ret.FeelsLikeC = accuMetric(hour.RealFeelTemperature, "C")
+
+	if p := hour.PrecipitationProbability; p != nil && *p >= 0 && *p <= 100 {
+		ret.ChanceOfRainPercent = p
+	}
+
+	if p := accuMetric(hour.TotalLiquid, "m"); p != nil && *p >= 0 {
+		ret.PrecipM = p
+	}
+
+	if p := accuMetric(hour.Visibility, "m"); p != nil && *p >= 0 {
+		ret.VisibleDistM = p
+	}
+
+	if p := accuMetric(hour.Wind.Speed, "km/h"); p != nil && *p >= 0 {
+		ret.WindspeedKmph = p
+	}
+
+	if p := accuMetric(hour.WindGust.Speed, "km/h"); p != nil && *p >= 0 {
+		ret.WindGustKmph = p
+	}
+
+	if p := hour.Wind.Direction.Degrees; p != nil && *p >= 0 {
+		d := *p % 360
+		ret.WinddirDegree = &d
+	}
+
+	if p := hour.RelativeHumidity; p != nil && *p >= 0 && *p <= 100 {
+		ret.Humidity = p
+	}
+
+	return ret, nil
+}
+
+func (c *accuConfig) parseDaily(hours []accuHour, numdays int, tz *time.Location) []iface.Day {
+	var forecast []iface.Day
+	var day *iface.Day
+
+	for _, hour := range hours {
+		slot, err := c.parseCond(hour, tz)
+		if err != nil {
+			log.Println("Error parsing hourly weather condition:", err)
+			continue
+		}
+
+		if day != nil && !forecastSameDate(day.Date, slot.Time) {
+			forecast = append(forecast, *day)
+			if len(forecast) >= numdays {
+				return forecast
+			}
+			day = nil
+		}
+		if day == nil {
+			day = new(iface.Day)
+			day.Date = slot.Time
+		}
+
+		day.Slots = append(day.Slots, slot)
+	}
+
+	if day != nil && len(forecast) < numdays {
+		forecast = append(forecast, *day)
+	}
+	return forecast
+}
+
+// MaxDays is two days, as the hourly forecast only covers the next 12 hours.
+func (c *accuConfig) MaxDays() int {
+	return 2
+}
+
+func (c *accuConfig) Setup() {
+	flag.StringVar(&c.apiKey, "accu-key", "", "accuweather backend: the api `KEY` to use")
+	flag.StringVar(&c.lang, "accu-lang", "en-us", "accuweather backend: the `LANGUAGE` to request from accuweather")
+	flag.BoolVar(&c.debug, "accu-debug", false, "accuweather backend: print raw requests and responses, same as -log-level debug")
+}
+
+// CheckConfig reports an error if the api key is missing.
+func (c *accuConfig) CheckConfig() error {
+	if len(c.apiKey) == 0 {
+		return fmt.Errorf("No accuweather API key specified.\nYou have to register for one at https://developer.accuweather.com/user/register")
+	}
+	return nil
+}
+
+// Fetch only returns the next 12 hours, because longer hourly forecasts are
+// not available with the free accuweather plan.
+func (c *accuConfig) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
+	var ret iface.Data
+	var hours []accuHour
+
+	if err := c.CheckConfig(); err != nil {
+		return ret, err
+	}
+	coords, err := iface.ResolveLatLon(ctx, location)
+	if err != nil {
+		return ret, fmt.Errorf("Could not find the location `%s`: %v\nTry a place name or `40.748,-73.985` for example to get a forecast for New York", location, err)
+	}
+	location = coords
+
+	loc, err := c.locationKey(ctx, location)
+	if err != nil {
+		return ret, fmt.Errorf("Failed to look up the accuweather location: %v", err)
+	}
+
+	// the config is shared by concurrent requests, so the timezone of the
+	// location is passed to the parse functions. Without it the days are split
+	// in UTC, not at the local midnight of the machine running wego.
+	tz := time.UTC
+	if loc.TimeZone.Name != "" {
+		if tz, err = time.LoadLocation(loc.TimeZone.Name); err != nil {
+			log.Printf("Unknown Timezone used in response (%s)", loc.TimeZone.Name)
+			tz = time.UTC
+		}
+	}
+
+	if err = iface.FetchJSON(ctx, fmt.Sprintf(accuWuri, loc.Key, c.apiKey, c.lang), nil, &hours); err != nil {
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+
+	ret.Attribution = "Data provided by AccuWeather"
+	ret.Source = "accuweather"
+	ret.Location = fmt.Sprintf("%s, %s", loc.LocalizedName, loc.Country.LocalizedName)
+	if loc.GeoPosition.Latitude != nil && loc.GeoPosition.Longitude != nil {
+		ret.GeoLoc = &iface.LatLon{Latitude: *loc.GeoPosition.Latitude, Longitude: *loc.GeoPosition.Longitude}
+	}
+
+	if len(hours) == 0 {
+		return ret, fmt.Errorf("The accuweather response did not contain any weather data")
+	}
+	if ret.Current, err = c.parseCond(hours[0], tz); err != nil {
+		return ret, fmt.Errorf("Could not parse current weather condition: %v", err)
+	}
+
+	if numdays >= 1 {
+		ret.Forecast = c.parseDaily(hours, numdays, tz)
+	}
+	return ret, nil
+}
+
+func init() {
+	iface.AllBackends["accuweather"] = &accuConfig{}
+}
@@ -0,0 +1,30 @@
+package backends
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccuParseDaily(t *testing.T) {
+	c := &accuConfig{}
+	var hours []accuHour
+	// the same day of different months must not end up in the same day
+	for _, ts := range []string{"2020-05-31T22:00:00Z", "2020-06-01T01:00:00Z", "2020-07-01T06:00:00Z"} {
+		tm, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		epoch := tm.Unix()
+		hours = append(hours, accuHour{EpochDateTime: &epoch})
+	}
+
+	days := c.parseDaily(hours, 5, time.FixedZone("CEST", 2*60*60))
+	if len(days) != 2 {
+		t.Fatalf("got %d days, want 2", len(days))
+	}
+	for i, want := range []string{"2020-06-01 00:00 CEST", "2020-07-01 08:00 CEST"} {
+		if got := days[i].Date.Format("2006-01-02 15:04 MST"); got != want {
+			t.Errorf("day %d: got %s, want %s", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type dwdConfig struct {
+	lang     string
+	timezone string
+	debug    bool
+	tz       *time.Location
+}
+
+type dwdRecord struct {
+	Timestamp                time.Time `json:"timestamp"`
+	Condition                string    `json:"condition"`
+	Icon                     string    `json:"icon"`
+	Temperature              *float32  `json:"temperature"`
+	Precipitation            *float32  `json:"precipitation"`
+	PrecipitationProbability *float32  `json:"precipitation_probability"`
+	RelativeHumidity         *float32  `json:"relative_humidity"`
+	Visibility               *float32  `json:"visibility"`
+	WindDirection            *float32  `json:"wind_direction"`
+	WindSpeed                *float32  `json:"wind_speed"`
+	WindGustSpeed            *float32  `json:"wind_gust_speed"`
+}
+
+type dwdResponse struct {
+	Weather []dwdRecord `json:"weather"`
+	Sources []struct {
+		StationName string `json:"station_name"`
+	} `json:"sources"`
+}
+
+const (
+	// see https://brightsky.dev/docs/
+	dwdWuri = "https://api.brightsky.dev/weather?lat=%s&lon=%s&date=%s&last_date=%s&tz=%s"
+
+	// precipitation in mm/h above which rain or snow is considered heavy
+	dwdHeavyPrecipMM = 4
+)
+
+// dwdSummaries contains the condition summary texts for each supported
+// language, because Bright Sky only provides machine readable conditions.
+var dwdSummaries = map[string]map[string]string{
+	"en": {
+		"clear-day":           "Clear",
+		"clear-night":         "Clear",
+		"partly-cloudy-day":   "Partly cloudy",
+		"partly-cloudy-night": "Partly cloudy",
+		"cloudy":              "Cloudy",
+		"fog":                 "Fog",
+		"wind":                "Windy",
+		"rain":                "Rain",
+		"sleet":               "Sleet",
+		"snow":                "Snow",
+		"hail":                "Hail",
+		"thunderstorm":        "Thunderstorm",
+	},
+	"de": {
+		"clear-day":           "Klar",
+		"clear-night":         "Klar",
+		"partly-cloudy-day":   "Teilweise bewölkt",
+		"partly-cloudy-night": "Teilweise bewölkt",
+		"cloudy":              "Bewölkt",
+		"fog":                 "Nebel",
+		"wind":                "Windig",
+		"rain":                "Regen",
+		"sleet":               "Schneeregen",
+		"snow":                "Schnee",
+		"hail":                "Hagel",
+		"thunderstorm":        "Gewitter",
+	},
+}
+
+// dwdCodemap maps the Bright Sky icons to weather codes.
+var dwdCodemap = map[string]iface.WeatherCode{
+	"clear-day":           iface.CodeSunny,
+	"clear-night":         iface.CodeSunny,
+	"partly-cloudy-day":   iface.CodePartlyCloudy,
+	"partly-cloudy-night": iface.CodePartlyCloudy,
+	"cloudy":              iface.CodeCloudy,
+	"fog":                 iface.CodeFog,
+	"wind":                iface.CodePartlyCloudy,
+	"rain":                iface.CodeLightRain,
+	"sleet":               iface.CodeLightSleet,
+	"snow":                iface.CodeLightSnow,
+	"hail":                iface.CodeLightSleet,
+	"thunderstorm":        iface.CodeThunderyShowers,
+}
+
+// CodeMap returns the Bright Sky icons and the weather codes they map to.
+func (c *dwdConfig) CodeMap() map[string]iface.WeatherCode {
+	return dwdCodemap
+}
+
+func (c *dwdConfig) parseCond(rec dwdRecord) (ret iface.Cond, err error) {
+	if rec.Timestamp.IsZero() {
+		return iface.Cond{}, fmt.Errorf("The brightsky response did not provide a time for the weather condition")
+	}
+	ret.Time = rec.Timestamp.In(c.tz)
+
+	ret.Code = iface.CodeUnknown
+	if val, ok := dwdCodemap[rec.Icon]; ok {
+		ret.Code = val
+	}
+	if rec.Precipitation != nil && *rec.Precipitation >= dwdHeavyPrecipMM {
+		switch ret.Code {
+		case iface.CodeLightRain:
+			ret.Code = iface.CodeHeavyRain
+		case iface.CodeLightSnow:
+			ret.Code = iface.CodeHeavySnow
+		case iface.CodeThunderyShowers:
+			ret.Code = iface.CodeThunderyHeavyRain
+		}
+	}
+	ret.Desc = dwdSummaries[c.lang][rec.Icon]
+
+	ret.TempC = rec.Temperature
+
+	if p := rec.PrecipitationProbability; p != nil && *p >= 0 && *p <= 100 {
+		r := int(math.Round(float64(*p)))
+		ret.ChanceOfRainPercent = &r
+	}
+
+	if p := rec.Precipitation; p != nil && *p >= 0 {
+		m := *p / 1000
+		ret.PrecipM = &m
+	}
+
+	if p := rec.Visibility; p != nil && *p >= 0 {
+		ret.VisibleDistM = p
+	}
+
+	if p := rec.WindSpeed; p != nil && *p >= 0 {
+		ret.WindspeedKmph = p
+	}
+
+	if p := rec.WindGustSpeed; p != nil && *p >= 0 {
+		ret.WindGustKmph = p
+	}
+
+	if p := rec.WindDirection; p != nil && *p >= 0 {
+		d := int(math.Round(float64(*p))) % 360
+		ret.WinddirDegree = &d
+	}
+
+	if p := rec.RelativeHumidity; p != nil && *p >= 0 && *p <= 100 {
+		h := int(math.Round(float64(*p)))
+		ret.Humidity = &h
+	}
+
+	// the api does not provide the apparent temperature
+	ret.FeelsLikeC = iface.ApparentTempC(ret.TempC, ret.Humidity, ret.WindspeedKmph)
+
+	return ret, nil
+}
+
+func (c *dwdConfig) parseDaily(records []dwdRecord, numdays int) []iface.Day {
+	var forecast []iface.Day
+	var day *iface.Day
+
+	for _, rec := range records {
+		slot, err := c.parseCond(rec)
+		if err != nil {
+			log.Println("Error parsing hourly weather condition:", err)
+			continue
+		}
+
+		if day != nil && day.Date.Day() != slot.Time.Day() {
+			forecast = append(forecast, *day)
+			if len(forecast) >= numdays {
+				return forecast
+			}
+			day = nil
+		}
+		if day == nil {
+			day = new(iface.Day)
+			day.Date = slot.Time
+		}
+
+		day.Slots = append(day.Slots, slot)
+	}
+
+	if day != nil && len(forecast) < numdays {
+		forecast = append(forecast, *day)
+	}
+	return forecast
+}
+
+func (c *dwdConfig) fetch(ctx context.Context, url string) (*dwdResponse, error) {
+	res, err := iface.Get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get (%s): %v", url, err)
+	} else if res.StatusCode != 200 {
+		res.Body.Close()
+		return nil, fmt.Errorf("Unable to get (%s): http status %d", url, res.StatusCode)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read response body (%s): %v", url, err)
+	}
+
+	iface.Logf(iface.LogDebug, "Response (%s): %s", url, string(body))
+
+	var resp dwdResponse
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal response (%s): %v\nThe json body is: %s", url, err, string(body))
+	}
+	return &resp, nil
+}
+
+// MaxDays is the ten days covered by the MOSMIX forecast.
+func (c *dwdConfig) MaxDays() int {
+	return 10
+}
+
+func (c *dwdConfig) Setup() {
+	flag.StringVar(&c.lang, "dwd-lang", "de", "dwd backend: the `LANGUAGE` of the condition summaries (de or en)")
+	flag.StringVar(&c.timezone, "dwd-tz", "Europe/Berlin", "dwd backend: the `TIMEZONE` to display the forecast in")
+	flag.BoolVar(&c.debug, "dwd-debug", false, "dwd backend: print raw requests and responses, same as -log-level debug")
+}
+
+func (c *dwdConfig) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
+	var ret iface.Data
+
+	if _, ok := dwdSummaries[c.lang]; !ok {
+		return ret, fmt.Errorf("The dwd backend does not support the language `%s`", c.lang)
+	}
+	coords, err := iface.ResolveLatLon(ctx, location)
+	if err != nil {
+		return ret, fmt.Errorf("Could not find the location `%s`: %v\nTry a place name or `52.520,13.405` for example to get a forecast for Berlin", location, err)
+	}
+	location = coords
+	s := strings.Split(location, ",")
+
+	if c.tz, err = time.LoadLocation(c.timezone); err != nil {
+		return ret, fmt.Errorf("Unknown timezone `%s`: %v", c.timezone, err)
+	}
+
+	days := numdays
+	if days < 1 {
+		days = 1
+	}
+	today := time.Now().In(c.tz)
+	first, last := today.Format("2006-01-02"), today.AddDate(0, 0, days).Format("2006-01-02")
+
+	resp, err := c.fetch(ctx, fmt.Sprintf(dwdWuri, s[0], s[1], first, last, url.QueryEscape(c.timezone)))
+	if err != nil {
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+	if len(resp.Weather) == 0 {
+		return ret, fmt.Errorf("The brightsky response did not contain any weather data")
+	}
+
+	ret.Attribution = "Data from Deutscher Wetterdienst via Bright Sky"
+	ret.Source = "dwd"
+	ret.Location = location
+	if len(resp.Sources) > 0 && resp.Sources[0].StationName != "" {
+		ret.Location = resp.Sources[0].StationName
+	}
+
+	// the records start at midnight, so pick the one closest to now
+	cur := resp.Weather[0]
+	for _, rec := range resp.Weather {
+		if rec.Timestamp.After(today) {
+			break
+		}
+		cur = rec
+	}
+	if ret.Current, err = c.parseCond(cur); err != nil {
+		return ret, fmt.Errorf("Could not parse current weather condition: %v", err)
+	}
+
+	if numdays >= 1 {
+		ret.Forecast = c.parseDaily(resp.Weather, numdays)
+	}
+	return ret, nil
+}
+
+func init() {
+	iface.AllBackends["dwd"] = &dwdConfig{}
+}
@@ -0,0 +1,318 @@
+package backends
+
+import (
+	"context"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type ecccConfig struct {
+	site  string
+	lang  string
+	debug bool
+}
+
+type ecccDateTime struct {
+	Name      string `xml:"name,attr"`
+	Zone      string `xml:"zone,attr"`
+	TimeStamp string `xml:"timeStamp"`
+}
+
+type ecccCond struct {
+	DateTimeUTC      string         `xml:"dateTimeUTC,attr"`
+	DateTime         []ecccDateTime `xml:"dateTime"`
+	Condition        string         `xml:"condition"`
+	IconCode         string         `xml:"iconCode"`
+	Temperature      string         `xml:"temperature"`
+	RelativeHumidity string         `xml:"relativeHumidity"`
+	Visibility       string         `xml:"visibility"`
+	Lop              string         `xml:"lop"`
+	Wind             struct {
+		Speed     string `xml:"speed"`
+		Gust      string `xml:"gust"`
+		Direction string `xml:"direction"`
+		Bearing   string `xml:"bearing"`
+	} `xml:"wind"`
+}
+
+type ecccResponse struct {
+	Location struct {
+		Name struct {
+			Value string `xml:",chardata"`
+			Lat   string `xml:"lat,attr"`
+			Lon   string `xml:"lon,attr"`
+		} `xml:"name"`
+		Province string `xml:"province"`
+	} `xml:"location"`
+	CurrentConditions   ecccCond `xml:"currentConditions"`
+	HourlyForecastGroup struct {
+		HourlyForecast []ecccCond `xml:"hourlyForecast"`
+	} `xml:"hourlyForecastGroup"`
+}
+
+const (
+	// see https://eccc-msc.github.io/open-data/msc-data/citypage-weather/readme_citypageweather-datamart_en/
+	ecccWuri = "https://dd.weather.gc.ca/citypage_weather/xml/%s_%s.xml"
+)
+
+// ecccFloat parses a numeric element value. Empty or malformed values, which
+// the feed uses for missing data, return nil.
+func ecccFloat(s string) *float32 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 32)
+	if err != nil {
+		return nil
+	}
+	ret := float32(v)
+	return &ret
+}
+
+// ecccCoord parses coordinates like "43.74N" or "79.37W".
+func ecccCoord(s string) (float32, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return 0, false
+	}
+	v := ecccFloat(s[:len(s)-1])
+	if v == nil {
+		return 0, false
+	}
+	if h := s[len(s)-1]; h == 'S' || h == 'W' {
+		return -*v, true
+	}
+	return *v, true
+}
+
+// ecccCodemap maps the environment canada icon codes to weather codes.
+var ecccCodemap = map[int]iface.WeatherCode{
+	0:  iface.CodeSunny,
+	1:  iface.CodeSunny,
+	2:  iface.CodePartlyCloudy,
+	3:  iface.CodeCloudy,
+	6:  iface.CodeLightShowers,
+	7:  iface.CodeLightSleetShowers,
+	8:  iface.CodeLightSnowShowers,
+	10: iface.CodeVeryCloudy,
+	11: iface.CodeLightRain,
+	12: iface.CodeLightRain,
+	13: iface.CodeHeavyRain,
+	14: iface.CodeLightSleet,
+	15: iface.CodeLightSleet,
+	16: iface.CodeLightSnow,
+	17: iface.CodeLightSnow,
+	18: iface.CodeHeavySnow,
+	19: iface.CodeThunderyShowers,
+	23: iface.CodeFog,
+	24: iface.CodeFog,
+	25: iface.CodeHeavySnow,
+	26: iface.CodeLightSnow,
+	27: iface.CodeLightSleet,
+	28: iface.CodeLightRain,
+	30: iface.CodeSunny,
+	31: iface.CodeSunny,
+	32: iface.CodePartlyCloudy,
+	33: iface.CodeCloudy,
+	36: iface.CodeLightRain,
+	37: iface.CodeLightSleet,
+	38: iface.CodeLightSnow,
+	39: iface.CodeThunderyShowers,
+	40: iface.CodeHeavySnow,
+	44: iface.CodeFog,
+	45: iface.CodeFog,
+	46: iface.CodeThunderyHeavyRain,
+	47: iface.CodeThunderyShowers,
+}
+
+// CodeMap returns the environment canada icon codes and the weather codes they map to.
+func (c *ecccConfig) CodeMap() map[string]iface.WeatherCode {
+	return iface.IntCodeMap(ecccCodemap)
+}
+
+func (c *ecccConfig) parseCond(cond ecccCond) (ret iface.Cond, err error) {
+	// hourly forecasts carry the time as attribute, current conditions as
+	// observation timestamp
+	stamp, layout := cond.DateTimeUTC, "200601021504"
+	for _, dt := range cond.DateTime {
+		if dt.Name == "observation" && dt.Zone == "UTC" {
+			stamp, layout = dt.TimeStamp, "20060102150405"
+		}
+	}
+	t, err := time.ParseInLocation(layout, stamp, time.UTC)
+	if err != nil {
+		return iface.Cond{}, fmt.Errorf("The eccc response did not provide a valid time for the weather condition: %v", err)
+	}
+	ret.Time = t.In(time.Local)
+
+	ret.Code = iface.CodeUnknown
+	if code, err := strconv.Atoi(strings.TrimSpace(cond.IconCode)); err == nil {
+		if val, ok := ecccCodemap[code]; ok {
+			ret.Code = val
+		}
+	}
+	ret.Desc = cond.Condition
+
+	ret.TempC = ecccFloat(cond.Temperature)
+
+	if p := ecccFloat(cond.Lop); p != nil && *p >= 0 && *p <= 100 {
+		r := int(math.Round(float64(*p)))
+		ret.ChanceOfRainPercent = &r
+	}
+
+	if p := ecccFloat(cond.Visibility); p != nil && *p >= 0 {
+		m := *p * 1000
+		ret.VisibleDistM = &m
+	}
+
+	if p := ecccFloat(cond.Wind.Speed); p != nil && *p >= 0 {
+		ret.WindspeedKmph = p
+	}
+
+	if p := ecccFloat(cond.Wind.Gust); p != nil && *p >= 0 {
+		ret.WindGustKmph = p
+	}
+
+	if p := ecccFloat(cond.Wind.Bearing); p != nil && *p >= 0 {
+		d := int(math.Round(float64(*p))) % 360
+		ret.WinddirDegree = &d
+	} else if d, ok := compassToDegree(cond.Wind.Direction); ok {
+		ret.WinddirDegree = &d
+	}
+
+	if p := ecccFloat(cond.RelativeHumidity); p != nil && *p >= 0 && *p <= 100 {
+		h := int(math.Round(float64(*p)))
+		ret.Humidity = &h
+	}
+
+	// the api does not provide the apparent temperature
+	ret.FeelsLikeC = iface.ApparentTempC(ret.TempC, ret.Humidity, ret.WindspeedKmph)
+
+	return ret, nil
+}
+
+func (c *ecccConfig) parseDaily(hours []ecccCond, numdays int) []iface.Day {
+	var forecast []iface.Day
+	var day *iface.Day
+
+	for _, hour := range hours {
+		slot, err := c.parseCond(hour)
+		if err != nil {
+			log.Println("Error parsing hourly weather condition:", err)
+			continue
+		}
+
+		if day != nil && day.Date.Day() != slot.Time.Day() {
+			forecast = append(forecast, *day)
+			if len(forecast) >= numdays {
+				return forecast
+			}
+			day = nil
+		}
+		if day == nil {
+			day = new(iface.Day)
+			day.Date = slot.Time
+		}
+
+		day.Slots = append(day.Slots, slot)
+	}
+
+	if day != nil && len(forecast) < numdays {
+		forecast = append(forecast, *day)
+	}
+	return forecast
+}
+
+func (c *ecccConfig) fetch(ctx context.Context, url string) (*ecccResponse, error) {
+	res, err := iface.Get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get (%s): %v", url, err)
+	} else if res.StatusCode != 200 {
+		res.Body.Close()
+		return nil, fmt.Errorf("Unable to get (%s): http status %d", url, res.StatusCode)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read response body (%s): %v", url, err)
+	}
+
+	iface.Logf(iface.LogDebug, "Response (%s): %s", url, string(body))
+
+	var resp ecccResponse
+	if err = xml.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal response (%s): %v\nThe xml body is: %s", url, err, string(body))
+	}
+	return &resp, nil
+}
+
+// MaxDays is two days, as the hourly forecast only covers the next 24 hours.
+func (c *ecccConfig) MaxDays() int {
+	return 2
+}
+
+func (c *ecccConfig) Setup() {
+	flag.StringVar(&c.site, "eccc-site", "", "eccc backend: the `SITE` code to query, like ON/s0000458 for Toronto")
+	flag.StringVar(&c.lang, "eccc-lang", "en", "eccc backend: the `LANGUAGE` to request from environment canada (en or fr)")
+	flag.BoolVar(&c.debug, "eccc-debug", false, "eccc backend: print raw requests and responses, same as -log-level debug")
+}
+
+// Fetch uses the configured site code. If none is configured, the location
+// must be a site code itself.
+func (c *ecccConfig) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
+	var ret iface.Data
+
+	site := c.site
+	if site == "" {
+		site = location
+	}
+	if matched, err := regexp.MatchString(`^[A-Z]{2}/s[0-9]{7}$`, site); !matched || err != nil {
+		return ret, fmt.Errorf("The eccc backend only supports site codes like `ON/s0000458`, not `%s`.\nSee https://dd.weather.gc.ca/citypage_weather/docs/site_list_en.csv for a list of all sites", site)
+	}
+
+	lang := "e"
+	if c.lang == "fr" {
+		lang = "f"
+	} else if c.lang != "en" {
+		return ret, fmt.Errorf("The eccc backend only supports the languages `en` and `fr`, not `%s`", c.lang)
+	}
+
+	resp, err := c.fetch(ctx, fmt.Sprintf(ecccWuri, site, lang))
+	if err != nil {
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+
+	name := resp.Location.Name
+	ret.Attribution = "Data Source: Environment and Climate Change Canada"
+	ret.Source = "eccc"
+	ret.Location = site
+	if name.Value != "" {
+		ret.Location = fmt.Sprintf("%s, %s", name.Value, resp.Location.Province)
+	}
+	lat, latOk := ecccCoord(name.Lat)
+	lon, lonOk := ecccCoord(name.Lon)
+	if latOk && lonOk {
+		ret.GeoLoc = &iface.LatLon{Latitude: lat, Longitude: lon}
+	}
+
+	if ret.Current, err = c.parseCond(resp.CurrentConditions); err != nil {
+		log.Printf("Could not parse current weather condition: %v", err)
+	}
+
+	if numdays >= 1 {
+		ret.Forecast = c.parseDaily(resp.HourlyForecastGroup.HourlyForecast, numdays)
+	}
+	return ret, nil
+}
+
+func init() {
+	iface.AllBackends["eccc"] = &ecccConfig{}
+}
@@ -1,23 +1,48 @@
 package backends
 
 import (
+	"context"
+	"crypto/sha1"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
-	"regexp"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/schachmat/wego/iface"
 )
 
 type forecastConfig struct {
-	apiKey string
-	lang   string
-	debug  bool
-	tz     *time.Location
+	apiKey    string
+	lang      string
+	debug     bool
+	host      string
+	client    *http.Client
+	fixture   string
+	units     string
+	userAgent string
+	proxy     string
+	retries   int
+	timeout   time.Duration
+	cacheTTL  time.Duration
+	exclude   string
+	extend    bool
+	location  string
+	overrides string
+	codemap   map[string]iface.WeatherCode
+
+	// prepared makes sure prepare runs only once, prepareErr is its result
+	prepared   sync.Once
+	prepareErr error
 }
 
 type forecastDataPoint struct {
@@ -26,14 +51,25 @@ type forecastDataPoint struct {
 	Icon                string   `json:"icon"`
 	SunriseTime         *int64   `json:"sunriseTime"`
 	SunsetTime          *int64   `json:"sunsetTime"`
+	MoonPhase           *float32 `json:"moonPhase"`
 	PrecipIntensity     *float32 `json:"precipIntensity"`
 	PrecipProb          *float32 `json:"precipProbability"`
+	PrecipType          string   `json:"precipType"`
 	Temperature         *float32 `json:"temperature"`
 	ApparentTemperature *float32 `json:"apparentTemperature"`
 	WindSpeed           *float32 `json:"windSpeed"`
+	WindGust            *float32 `json:"windGust"`
 	WindBearing         *float32 `json:"windBearing"`
 	Visibility          *float32 `json:"visibility"`
 	Humidity            *float32 `json:"humidity"`
+	DewPoint            *float32 `json:"dewPoint"`
+	Pressure            *float32 `json:"pressure"`
+	UVIndex             *float32 `json:"uvIndex"`
+	CloudCover          *float32 `json:"cloudCover"`
+
+	// only set for the current conditions
+	NearestStormDistance *float32 `json:"nearestStormDistance"`
+	NearestStormBearing  *float32 `json:"nearestStormBearing"`
 }
 
 type forecastDataBlock struct {
@@ -42,104 +78,258 @@ type forecastDataBlock struct {
 	Data    []forecastDataPoint `json:"data"`
 }
 
+type forecastAlert struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	Expires     *int64 `json:"expires"`
+}
+
 type forecastResponse struct {
 	Latitude  *float32          `json:"latitude"`
 	Longitude *float32          `json:"longitude"`
 	Timezone  *string           `json:"timezone"`
 	Currently forecastDataPoint `json:"currently"`
+	Minutely  forecastDataBlock `json:"minutely"`
 	Hourly    forecastDataBlock `json:"hourly"`
 	Daily     forecastDataBlock `json:"daily"`
+	Alerts    []forecastAlert   `json:"alerts"`
+	Flags     struct {
+		Units string `json:"units"`
+	} `json:"flags"`
+
+	// tz is the location of Timezone, or the local one if unknown
+	tz *time.Location
+}
+
+// forecastCacheEntry is stored on disk between invocations, so repeated runs
+// within the cache TTL do not hit the api again.
+type forecastCacheEntry struct {
+	Fetched time.Time
+	Body    json.RawMessage
+}
+
+// forecastUnits describes how to convert the values of a forecast.io units
+// mode into the metric units of iface.Cond.
+type forecastUnits struct {
+	fahrenheit bool
+	kmph       float32 // wind speed factor to km/h
+	km         float32 // visibility and storm distance factor to km
+	mm         float32 // precipitation intensity factor to mm/h
+}
+
+var forecastAllUnits = map[string]forecastUnits{
+	"ca":  {false, 1, 1, 1},
+	"si":  {false, 3.6, 1, 1},
+	"uk2": {false, 1.609344, 1.609344, 1},
+	"us":  {true, 1.609344, 1.609344, 25.4},
 }
 
 const (
 	// see https://developer.forecast.io/docs/v2
 	// see also https://github.com/mlbright/forecast
 	//https://api.forecast.io/forecast/APIKEY/LATITUDE,LONGITUDE
-	forecastWuri = "https://api.forecast.io/forecast/%s/%s?units=ca&lang=%s&exclude=minutely,alerts,flags&extend=hourly"
+	forecastWuri = "%s/forecast/%s/%s?units=%s&lang=%s"
+	forecastHost = "https://api.forecast.io"
+
+	// precipitation in mm/h above which rain or snow is considered heavy
+	forecastHeavyPrecipMM = 4
 )
 
-func (c *forecastConfig) parseAstro(cur *iface.Day, days []forecastDataPoint) {
+// parseAstro sets the sunrise, sunset and moon phase of the daily datapoint
+// which falls on the same calendar date as cur in the timezone tz of the
+// response.
+func (c *forecastConfig) parseAstro(cur *iface.Day, days []forecastDataPoint, tz *time.Location) {
+	y, m, d := cur.Date.In(tz).Date()
 	for _, day := range days {
-		if day.Time != nil && cur.Date.Day() == time.Unix(*day.Time, 0).In(c.tz).Day() {
+		if day.Time == nil {
+			continue
+		}
+		if dy, dm, dd := time.Unix(*day.Time, 0).In(tz).Date(); dy == y && dm == m && dd == d {
 			if day.SunriseTime != nil {
-				cur.Astronomy.Sunrise = time.Unix(*day.SunriseTime, 0).In(c.tz)
+				cur.Astronomy.Sunrise = time.Unix(*day.SunriseTime, 0).In(tz)
 			}
 			if day.SunsetTime != nil {
-				cur.Astronomy.Sunset = time.Unix(*day.SunsetTime, 0).In(c.tz)
+				cur.Astronomy.Sunset = time.Unix(*day.SunsetTime, 0).In(tz)
+			}
+			if day.MoonPhase != nil && *day.MoonPhase >= 0 && *day.MoonPhase <= 1 {
+				cur.MoonPhase = day.MoonPhase
 			}
 			return
 		}
 	}
 }
 
-func (c *forecastConfig) parseDaily(hours, days forecastDataBlock, numdays int) []iface.Day {
+// forecastSameDate reports whether a and b are on the same calendar date. The
+// whole date is compared, because days of different months have the same day
+// of the month when data is missing.
+func forecastSameDate(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+func (c *forecastConfig) parseDaily(hours, days forecastDataBlock, numdays int, tz *time.Location) []iface.Day {
 	var forecast []iface.Day
 	var day *iface.Day
+	if numdays <= 0 {
+		return nil
+	}
 
+	var slots []iface.Cond
 	for _, hourData := range hours.Data {
-		slot, err := c.parseCond(hourData)
+		slot, err := c.parseCond(hourData, tz)
 		if err != nil {
 			log.Println("Error parsing hourly weather condition:", err)
 			continue
 		}
+		slots = append(slots, slot)
+	}
+	// mirrors do not always return the data points in order
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].Time.Before(slots[j].Time)
+	})
 
-		if day != nil && day.Date.Day() != slot.Time.Day() {
+	for _, slot := range slots {
+		if day != nil && !forecastSameDate(day.Date, slot.Time) {
 			if len(forecast) >= numdays-1 {
 				break
 			}
+			iface.SummarizeDay(day)
 			forecast = append(forecast, *day)
 			day = nil
 		}
 		if day == nil {
 			day = new(iface.Day)
 			day.Date = slot.Time
-			c.parseAstro(day, days.Data)
+			c.parseAstro(day, days.Data, tz)
 		}
 
 		day.Slots = append(day.Slots, slot)
 	}
+
+	// no slots could be parsed for the last day
+	if day == nil {
+		return forecast
+	}
+	iface.SummarizeDay(day)
 	return append(forecast, *day)
 }
 
-func (c *forecastConfig) parseCond(dp forecastDataPoint) (ret iface.Cond, err error) {
-	codemap := map[string]iface.WeatherCode{
-		"clear-day":           iface.CodeSunny,
-		"clear-night":         iface.CodeSunny,
-		"rain":                iface.CodeLightRain,
-		"snow":                iface.CodeLightSnow,
-		"sleet":               iface.CodeLightSleet,
-		"wind":                iface.CodePartlyCloudy,
-		"fog":                 iface.CodeFog,
-		"cloudy":              iface.CodeCloudy,
-		"partly-cloudy-day":   iface.CodePartlyCloudy,
-		"partly-cloudy-night": iface.CodePartlyCloudy,
-		"thunderstorm":        iface.CodeThunderyShowers,
+// forecastPercent converts a fraction in [0, 1] to a rounded percentage.
+// Slightly out of range values as returned by some mirrors are clamped.
+func forecastPercent(f *float32) *int {
+	if f == nil {
+		return nil
 	}
+	p := int(math.Round(float64(*f * 100)))
+	if p < 0 {
+		p = 0
+	} else if p > 100 {
+		p = 100
+	}
+	return &p
+}
+
+// forecastCodemap maps the forecast.io icons to weather codes.
+var forecastCodemap = map[string]iface.WeatherCode{
+	"clear-day":           iface.CodeSunny,
+	"clear-night":         iface.CodeSunny,
+	"rain":                iface.CodeLightRain,
+	"snow":                iface.CodeLightSnow,
+	"sleet":               iface.CodeLightSleet,
+	"wind":                iface.CodePartlyCloudy,
+	"fog":                 iface.CodeFog,
+	"cloudy":              iface.CodeCloudy,
+	"partly-cloudy-day":   iface.CodePartlyCloudy,
+	"partly-cloudy-night": iface.CodePartlyCloudy,
+	"thunderstorm":        iface.CodeThunderyShowers,
+	"hail":                iface.CodeHail,
+	"tornado":             iface.CodeTornado,
+}
+
+// parseCodemap sets up the codemap from forecastCodemap and the overrides,
+// which are comma separated pairs of an icon and the name of a weather code,
+// like fog=Cloudy.
+func (c *forecastConfig) parseCodemap() error {
+	codemap := make(map[string]iface.WeatherCode, len(forecastCodemap))
+	for k, v := range forecastCodemap {
+		codemap[k] = v
+	}
+	for _, o := range strings.Split(c.overrides, ",") {
+		if strings.TrimSpace(o) == "" {
+			continue
+		}
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("Invalid forecast.io icon override `%s`, use the form icon=WeatherCode", o)
+		}
+		code, err := iface.ParseWeatherCode(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return fmt.Errorf("Invalid forecast.io icon override `%s`: %v", o, err)
+		}
+		codemap[strings.TrimSpace(kv[0])] = code
+	}
+	c.codemap = codemap
+	return nil
+}
 
+// CodeMap returns the forecast.io icons and the weather codes they map to,
+// including the overrides.
+func (c *forecastConfig) CodeMap() map[string]iface.WeatherCode {
+	if c.codemap == nil && c.parseCodemap() != nil {
+		return forecastCodemap
+	}
+	return c.codemap
+}
+
+func (c *forecastConfig) parseCond(dp forecastDataPoint, tz *time.Location) (ret iface.Cond, err error) {
 	if dp.Time == nil {
 		return iface.Cond{}, fmt.Errorf("The forecast.io response did not provide a time for the weather condition")
 	}
-	ret.Time = time.Unix(*dp.Time, 0).In(c.tz)
+	ret.Time = time.Unix(*dp.Time, 0).In(tz)
 
 	ret.Code = iface.CodeUnknown
-	if val, ok := codemap[dp.Icon]; ok {
+	if val, ok := c.codemap[dp.Icon]; ok {
 		ret.Code = val
 	}
+
+	// the icons do not distinguish intensities and are sometimes generic, so
+	// refine precipitation codes with the precipitation type and intensity
+	if ret.Code == iface.CodeLightRain || ret.Code == iface.CodeLightSnow || ret.Code == iface.CodeLightSleet {
+		heavy := dp.PrecipIntensity != nil && *dp.PrecipIntensity >= forecastHeavyPrecipMM
+		switch dp.PrecipType {
+		case "rain":
+			ret.Code = iface.CodeLightRain
+		case "snow":
+			ret.Code = iface.CodeLightSnow
+		case "sleet":
+			ret.Code = iface.CodeLightSleet
+		case "hail":
+			ret.Code = iface.CodeHail
+		}
+		if ret.Code == iface.CodeLightRain && dp.Temperature != nil && *dp.Temperature < 0 {
+			ret.Code = iface.CodeFreezingRain
+		} else if heavy && ret.Code == iface.CodeLightRain {
+			ret.Code = iface.CodeHeavyRain
+		} else if heavy && ret.Code == iface.CodeLightSnow {
+			ret.Code = iface.CodeHeavySnow
+		}
+	}
 	ret.Desc = dp.Summary
 
 	ret.TempC = dp.Temperature
 	ret.FeelsLikeC = dp.ApparentTemperature
 
-	if dp.PrecipProb != nil && *dp.PrecipProb >= 0 && *dp.PrecipProb <= 1 {
-		p := int(*dp.PrecipProb * 100)
-		ret.ChanceOfRainPercent = &p
-	}
+	ret.ChanceOfRainPercent = forecastPercent(dp.PrecipProb)
 
 	if dp.PrecipIntensity != nil && *dp.PrecipIntensity >= 0 {
 		p := *dp.PrecipIntensity / 1000
 		ret.PrecipM = &p
 	}
 
+	// toMetric already converted the visibility from miles to km for the us
+	// and uk2 units
 	if dp.Visibility != nil && *dp.Visibility >= 0 {
 		p := *dp.Visibility * 1000
 		ret.VisibleDistM = &p
@@ -149,102 +339,389 @@ func (c *forecastConfig) parseCond(dp forecastDataPoint) (ret iface.Cond, err er
 		ret.WindspeedKmph = dp.WindSpeed
 	}
 
-	//ret.WindGustKmph not provided by forecast.io :(
+	if dp.WindGust != nil && *dp.WindGust >= 0 {
+		ret.WindGustKmph = dp.WindGust
+	}
 
 	if dp.WindBearing != nil && *dp.WindBearing >= 0 {
-		p := int(*dp.WindBearing) % 360
+		p := int(math.Round(float64(*dp.WindBearing))) % 360
 		ret.WinddirDegree = &p
 	}
 
-	if dp.Humidity != nil && *dp.Humidity >= 0 && *dp.Humidity <= 1 {
-		p := int(*dp.Humidity * 100)
-		ret.Humidity = &p
+	ret.Humidity = forecastPercent(dp.Humidity)
+
+	ret.DewPointC = dp.DewPoint
+
+	if dp.Pressure != nil && *dp.Pressure >= 0 {
+		ret.PressureHPa = dp.Pressure
+	}
+
+	if dp.UVIndex != nil && *dp.UVIndex >= 0 {
+		p := int(math.Round(float64(*dp.UVIndex)))
+		ret.UVIndex = &p
+	}
+
+	ret.CloudCoverPercent = forecastPercent(dp.CloudCover)
+
+	if ret.FeelsLikeC == nil {
+		ret.FeelsLikeC = iface.ApparentTempC(ret.TempC, ret.Humidity, ret.WindspeedKmph)
 	}
 
 	return ret, nil
 }
 
-func (c *forecastConfig) fetch(url string) (*forecastResponse, error) {
-	res, err := http.Get(url)
+// toMetric converts the values of dp in place from the units u to the ones
+// expected by parseCond.
+func (u forecastUnits) toMetric(dp *forecastDataPoint) {
+	for _, t := range []*float32{dp.Temperature, dp.ApparentTemperature, dp.DewPoint} {
+		if t != nil && u.fahrenheit {
+			*t = (*t - 32) / 1.8
+		}
+	}
+	for _, s := range []*float32{dp.WindSpeed, dp.WindGust} {
+		if s != nil {
+			*s *= u.kmph
+		}
+	}
+	for _, d := range []*float32{dp.Visibility, dp.NearestStormDistance} {
+		if d != nil {
+			*d *= u.km
+		}
+	}
+	if dp.PrecipIntensity != nil {
+		*dp.PrecipIntensity *= u.mm
+	}
+}
+
+// forecastClient is shared by all forecast.io requests. It is the default
+// client of the backend, which can be replaced together with the host to send
+// the requests to a different server.
+var forecastClient = &http.Client{}
+
+// setupProxy routes all forecast.io requests through the configured proxy or
+// the one from the environment if none is configured. The client is copied
+// first, as forecastClient is shared with the pirateweather backend.
+func (c *forecastConfig) setupProxy() error {
+	client := *c.client
+	client.Transport = iface.HTTPClient.Transport
+	if c.proxy != "" {
+		u, err := url.Parse(c.proxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("Invalid forecast.io proxy URL `%s`. Use something like `http://proxy.example.com:3128`", c.proxy)
+		}
+		t := iface.HTTPClient.Transport.(*http.Transport).Clone()
+		t.Proxy = http.ProxyURL(u)
+		client.Transport = t
+	}
+	c.client = &client
+	return nil
+}
+
+// prepare validates the flags and sets up the state shared by all requests.
+// Fetch is called concurrently for several locations, so this is done only
+// once and the requests only read the config afterwards.
+func (c *forecastConfig) prepare() error {
+	c.prepared.Do(func() {
+		c.host = strings.TrimRight(c.host, "/")
+		if len(c.apiKey) == 0 {
+			c.apiKey = os.Getenv("FORECAST_API_KEY")
+		}
+		if _, ok := forecastAllUnits[c.units]; !ok && c.units != "auto" {
+			c.prepareErr = fmt.Errorf("Unknown forecast.io units `%s`. Use one of ca, us, si, uk2 or auto", c.units)
+			return
+		}
+		if c.exclude, c.prepareErr = c.parseExclude(); c.prepareErr != nil {
+			return
+		}
+		if c.prepareErr = c.parseCodemap(); c.prepareErr != nil {
+			return
+		}
+		c.prepareErr = c.setupProxy()
+	})
+	return c.prepareErr
+}
+
+// cacheFile returns the path of the cache file for the given key. The host,
+// language, units and excluded blocks are part of the file name, because they
+// change the response.
+func (c *forecastConfig) cacheFile(key string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%s|%s|%s|%t", c.host, key, c.lang, c.units, c.exclude, c.extend)))
+	return filepath.Join(dir, "wego", fmt.Sprintf("forecast_%x.json", sum))
+}
+
+func (c *forecastConfig) readCache(path string) (entry forecastCacheEntry) {
+	if path == "" {
+		return
+	}
+	b, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to get (%s): %v", url, err)
-	} else if res.StatusCode != 200 {
-		return nil, fmt.Errorf("Unable to get (%s): http status %d", url, res.StatusCode)
+		return
+	}
+	if err = json.Unmarshal(b, &entry); err != nil {
+		iface.Logf(iface.LogDebug, "Ignoring broken forecast.io cache file (%s): %v", path, err)
 	}
-	defer res.Body.Close()
+	return
+}
 
-	body, err := ioutil.ReadAll(res.Body)
+func (c *forecastConfig) writeCache(path string, entry forecastCacheEntry) {
+	if path == "" {
+		return
+	}
+	b, err := json.Marshal(entry)
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			err = ioutil.WriteFile(path, b, 0644)
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("Unable to read response body (%s): %v", url, err)
+		log.Printf("Unable to write forecast.io cache file (%s): %v", path, err)
 	}
+}
 
-	if c.debug {
-		log.Printf("Response (%s): %s\n", url, string(body))
+// redact masks the api key in url for logs and error messages.
+func (c *forecastConfig) redact(url string) string {
+	return iface.RedactURL(url, c.apiKey)
+}
+
+// fetch gets and decodes the response for url. With a cache TTL set, the
+// response is cached on disk under key and stale data is used as a fallback
+// when the api can not be reached.
+func (c *forecastConfig) fetch(ctx context.Context, url, key string) (*forecastResponse, error) {
+	var entry forecastCacheEntry
+	var path string
+	if c.fixture != "" {
+		body, err := ioutil.ReadFile(c.fixture)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read fixture: %v", err)
+		}
+		entry.Body = body
+	} else if c.cacheTTL > 0 {
+		path = c.cacheFile(key)
+		entry = c.readCache(path)
 	}
 
+	if c.fixture == "" && (len(entry.Body) == 0 || time.Since(entry.Fetched) > c.cacheTTL) {
+		header := http.Header{}
+		if c.userAgent != "" {
+			header.Set("User-Agent", c.userAgent)
+		}
+		body, err := iface.FetchBody(ctx, url, iface.FetchOptions{Client: c.client, Header: header, Retries: c.retries, Timeout: c.timeout})
+		if err != nil {
+			err = fmt.Errorf("Unable to get (%s): %v", c.redact(url), err)
+		}
+		if err != nil && len(entry.Body) == 0 {
+			return nil, err
+		} else if err != nil {
+			log.Printf("Using cached forecast.io data from %s: %v", entry.Fetched.Format(time.RFC1123), err)
+		} else {
+			entry = forecastCacheEntry{Fetched: time.Now(), Body: body}
+			if c.cacheTTL > 0 {
+				c.writeCache(path, entry)
+			}
+		}
+	}
+	shown := c.redact(url)
+	body := []byte(entry.Body)
+
+	iface.Logf(iface.LogDebug, "Response (%s): %s", shown, string(body))
+
 	var resp forecastResponse
+	var err error
 	if err = json.Unmarshal(body, &resp); err != nil {
-		return nil, fmt.Errorf("Unable to unmarshal response (%s): %v\nThe json body is: %s", url, err, string(body))
+		return nil, fmt.Errorf("Unable to unmarshal response (%s): %v\nThe json body is: %s", shown, err, string(body))
 	}
 
+	// with units=auto only the flags tell which units were chosen
+	units, ok := forecastAllUnits[resp.Flags.Units]
+	if !ok {
+		if units, ok = forecastAllUnits[c.units]; !ok {
+			return nil, fmt.Errorf("Unable to determine the units of the response (%s)", shown)
+		}
+	}
+	units.toMetric(&resp.Currently)
+	for _, block := range []*forecastDataBlock{&resp.Minutely, &resp.Hourly, &resp.Daily} {
+		for i := range block.Data {
+			units.toMetric(&block.Data[i])
+		}
+	}
+
+	resp.tz = time.Local
 	if resp.Timezone == nil {
-		log.Printf("No timezone set in response (%s)", url)
+		log.Printf("No timezone set in response (%s)", shown)
+	} else if tz, err := time.LoadLocation(*resp.Timezone); err != nil {
+		log.Printf("Unknown Timezone used in response (%s)", shown)
 	} else {
-		c.tz, err = time.LoadLocation(*resp.Timezone)
-		if err != nil {
-			log.Printf("Unknown Timezone used in response (%s)", url)
-		}
+		resp.tz = tz
 	}
 	return &resp, nil
 }
 
-func (c *forecastConfig) fetchToday(location string) ([]iface.Cond, error) {
-	location = fmt.Sprintf("%s,%d", location, time.Now().Unix())
+// forecastBlocks are the parts of the response which can be excluded.
+var forecastBlocks = []string{"currently", "minutely", "hourly", "daily", "alerts", "flags"}
+
+// parseExclude validates the comma separated list of excluded blocks and
+// returns it without spaces.
+func (c *forecastConfig) parseExclude() (string, error) {
+	var ret []string
+	for _, block := range strings.Split(c.exclude, ",") {
+		block = strings.TrimSpace(block)
+		if block == "" {
+			continue
+		}
+		found := false
+		for _, b := range forecastBlocks {
+			found = found || b == block
+		}
+		if !found {
+			return "", fmt.Errorf("Unknown forecast.io block `%s` to exclude. Use some of %s", block, strings.Join(forecastBlocks, ", "))
+		}
+		ret = append(ret, block)
+	}
+	return strings.Join(ret, ","), nil
+}
+
+// requestURL returns the request url for location, which may contain a time
+// for time machine requests.
+func (c *forecastConfig) requestURL(location string) string {
+	ret := fmt.Sprintf(forecastWuri, c.host, c.apiKey, location, c.units, c.lang)
+	if c.exclude != "" {
+		ret += "&exclude=" + c.exclude
+	}
+	if c.extend {
+		ret += "&extend=hourly"
+	}
+	return ret
+}
+
+// todayURL returns the time machine request url for the date of today, which
+// must be given in the timezone of the location.
+func (c *forecastConfig) todayURL(location string, today time.Time) string {
+	return c.requestURL(fmt.Sprintf("%s,%sT00:00:00", location, today.Format("2006-01-02")))
+}
 
-	resp, err := c.fetch(fmt.Sprintf(forecastWuri, c.apiKey, location, c.lang))
+// fetchToday gets the conditions of the whole day of today. The time machine
+// request is made for midnight without a timezone offset, which the api
+// interprets as midnight at the requested location. The date is part of the
+// cache key, so cached data of the previous day is not used after midnight.
+func (c *forecastConfig) fetchToday(ctx context.Context, location string, today time.Time) ([]iface.Cond, error) {
+	date := today.Format("2006-01-02")
+	resp, err := c.fetch(ctx, c.todayURL(location, today), "today_"+location+"_"+date)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to fetch todays weather data: %v\n", err)
+		return nil, err
 	}
 
-	days := c.parseDaily(resp.Hourly, resp.Daily, 1)
+	days := c.parseDaily(resp.Hourly, resp.Daily, 1, resp.tz)
 	if len(days) < 1 {
-		return nil, fmt.Errorf("Failed to parse today\n")
+		return nil, fmt.Errorf("The forecast.io response did not contain any data for today")
 	}
 	return days[0].Slots, nil
 }
 
+// MaxDays is eight days with the extended hourly data and two days without.
+func (c *forecastConfig) MaxDays() int {
+	if c.extend {
+		return 8
+	}
+	return 2
+}
+
 func (c *forecastConfig) Setup() {
-	flag.StringVar(&c.apiKey, "forecast-api-key", "", "forecast backend: the api `KEY` to use")
+	flag.StringVar(&c.apiKey, "forecast-api-key", "", "forecast backend: the api `KEY` to use, defaults to the FORECAST_API_KEY environment variable")
 	flag.StringVar(&c.lang, "forecast-lang", "en", "forecast backend: the `LANGUAGE` to request from forecast.io")
-	flag.BoolVar(&c.debug, "forecast-debug", false, "forecast backend: print raw requests and responses")
+	flag.StringVar(&c.units, "forecast-units", "ca", "forecast backend: the `UNITS` to request from forecast.io (ca, us, si, uk2 or auto)")
+	flag.StringVar(&c.userAgent, "forecast-user-agent", "", "forecast backend: the `USERAGENT` to send to forecast.io instead of wego/VERSION")
+	flag.StringVar(&c.proxy, "forecast-proxy", "", "forecast backend: the `URL` of the proxy to use, defaults to the HTTP_PROXY and HTTPS_PROXY environment variables")
+	flag.IntVar(&c.retries, "forecast-retries", 3, "forecast backend: the `NUMBER` of times to retry failed requests")
+	flag.BoolVar(&c.debug, "forecast-debug", false, "forecast backend: print raw requests and responses, same as -log-level debug")
+	flag.DurationVar(&c.cacheTTL, "forecast-cache-ttl", 0, "forecast backend: reuse responses cached on disk for `DURATION`, 0 disables the cache")
+	flag.StringVar(&c.location, "forecast-default-location", "", "forecast backend: the latitude,longitude `LOCATION` to use with this backend if none is given on the command line, overrides -location")
+	flag.StringVar(&c.overrides, "forecast-icon-overrides", "", "forecast backend: comma separated `OVERRIDES` of the weather codes for forecast.io icons, like fog=Cloudy,wind=Sunny. See -dump-codemap for the icons and codes")
+	flag.StringVar(&c.exclude, "forecast-exclude", "", "forecast backend: comma separated `BLOCKS` to exclude from the response: currently, minutely, hourly, daily, alerts or flags")
+	flag.BoolVar(&c.extend, "forecast-extend", true, "forecast backend: request hourly data for the next week instead of two days")
+	flag.StringVar(&c.fixture, "forecast-fixture", "", "forecast backend: read the response from the json `FILE` instead of requesting it, to reproduce problems with a saved response")
+	flag.DurationVar(&c.timeout, "forecast-timeout", 30*time.Second, "forecast backend: the `DURATION` to wait for a single response from forecast.io before retrying, the -timeout flag limits the total time")
 }
 
-func (c *forecastConfig) Fetch(location string, numdays int) iface.Data {
-	var ret iface.Data
-	todayChan := make(chan []iface.Cond)
+// forecastMergeSlots merges the sorted slots of history and future into one
+// sorted list. If both contain a slot for the same time, the one from future
+// is used.
+func forecastMergeSlots(history, future []iface.Cond) (ret []iface.Cond) {
+	h, f := 0, 0
+	for h < len(history) && f < len(future) {
+		switch ht, ft := history[h].Time, future[f].Time; {
+		case ht.Before(ft):
+			ret = append(ret, history[h])
+			h++
+		case ht.After(ft):
+			ret = append(ret, future[f])
+			f++
+		default: // same time
+			ret = append(ret, future[f])
+			h++
+			f++
+		}
+	}
+	ret = append(ret, history[h:]...)
+	return append(ret, future[f:]...)
+}
 
-	if len(c.apiKey) == 0 {
-		log.Fatal("No forecast.io API key specified.\nYou have to register for one at https://developer.forecast.io/register")
+// DefaultLocation returns the configured default location, which must be a
+// latitude,longitude pair.
+func (c *forecastConfig) DefaultLocation() (string, error) {
+	if c.location != "" && !iface.IsLatLon(c.location) {
+		return "", fmt.Errorf("The default location `%s` is not a latitude,longitude pair like `40.748,-73.985`", c.location)
 	}
-	if matched, err := regexp.MatchString(`^-?[0-9]*(\.[0-9]+)?,-?[0-9]*(\.[0-9]+)?$`, location); !matched || err != nil {
-		log.Fatalf("Error: The forecast.io backend only supports latitude,longitude pairs as location.\nInstead of `%s` try `40.748,-73.985` for example to get a forecast for New York", location)
+	return c.location, nil
+}
+
+// CheckConfig reports an error if the api key is missing.
+func (c *forecastConfig) CheckConfig() error {
+	if c.fixture != "" {
+		return nil
+	}
+	if len(c.apiKey) == 0 && os.Getenv("FORECAST_API_KEY") == "" {
+		return fmt.Errorf("No forecast.io API key specified with -forecast-api-key or the FORECAST_API_KEY environment variable.\nYou have to register for one at https://developer.forecast.io/register")
 	}
+	return nil
+}
 
-	c.tz = time.Local
+func (c *forecastConfig) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
+	var ret iface.Data
 
-	go func() {
-		slots, err := c.fetchToday(location)
-		if err != nil {
-			log.Fatalf("Failed to fetch todays weather data: %v\n", err)
+	if err := c.CheckConfig(); err != nil {
+		return ret, err
+	}
+	if err := c.prepare(); err != nil {
+		return ret, err
+	}
+	coords, err := iface.ResolveLatLon(ctx, location)
+	if err != nil {
+		return ret, fmt.Errorf("Could not find the location `%s`: %v\nTry a place name or `40.748,-73.985` for example to get a forecast for New York", location, err)
+	}
+	location = coords
+
+	// the history request depends on the forecast response, so the urls are
+	// printed up front. Without the response the date of the history is the
+	// local one.
+	wuri := c.requestURL(location)
+	if iface.DryRun {
+		if numdays >= 1 {
+			iface.CheckDryRun(c.todayURL(location, time.Now()), c.apiKey)
 		}
-		todayChan <- slots
-	}()
+		return ret, iface.CheckDryRun(wuri, c.apiKey)
+	}
 
-	resp, err := c.fetch(fmt.Sprintf(forecastWuri, c.apiKey, location, c.lang))
+	resp, err := c.fetch(ctx, wuri, location)
 	if err != nil {
-		log.Fatalf("Failed to fetch weather data: %v\n", err)
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
 	}
 
+	ret.Attribution = "Powered by Dark Sky"
+	ret.Source = "forecast.io"
 	if resp.Latitude == nil || resp.Longitude == nil {
 		log.Println("nil response for latitude,longitude")
 		ret.Location = location
@@ -253,39 +730,55 @@ func (c *forecastConfig) Fetch(location string, numdays int) iface.Data {
 		ret.Location = fmt.Sprintf("%f,%f", *resp.Latitude, *resp.Longitude)
 	}
 
-	if ret.Current, err = c.parseCond(resp.Currently); err != nil {
-		log.Fatalf("Could not parse current weather condition: %v", err)
+	if ret.Current, err = c.parseCond(resp.Currently, resp.tz); err != nil {
+		return ret, fmt.Errorf("Could not parse current weather condition: %v", err)
+	}
+	if d := resp.Currently.NearestStormDistance; d != nil && *d >= 0 {
+		m := *d * 1000
+		ret.Current.NearestStormDistM = &m
+		if b := resp.Currently.NearestStormBearing; b != nil && *b >= 0 {
+			deg := int(math.Round(float64(*b))) % 360
+			ret.Current.NearestStormBearing = &deg
+		}
+	}
+
+	for _, a := range resp.Alerts {
+		alert := iface.Alert{Title: a.Title, Description: a.Description, Severity: a.Severity}
+		if a.Expires != nil {
+			alert.Expires = time.Unix(*a.Expires, 0).In(resp.tz)
+		}
+		ret.Alerts = append(ret.Alerts, alert)
+	}
+
+	for _, dp := range resp.Minutely.Data {
+		slot, err := c.parseCond(dp, resp.tz)
+		if err != nil {
+			log.Println("Error parsing minutely weather condition:", err)
+			continue
+		}
+		ret.Nowcast = append(ret.Nowcast, slot)
 	}
 
 	if numdays >= 1 {
-		ret.Forecast = c.parseDaily(resp.Hourly, resp.Daily, numdays)
-
-		var tHistory, tFuture = <-todayChan, ret.Forecast[0].Slots
-		var tRet []iface.Cond
-		h, f := 0, 0
-
-		// merge forecast and history from current day
-		for h < len(tHistory) || f < len(tFuture) {
-			if f >= len(tFuture) {
-				tRet = append(tRet, tHistory[h])
-				h++
-			} else if h >= len(tHistory) || tHistory[h].Time.After(tFuture[f].Time) {
-				tRet = append(tRet, tFuture[f])
-				f++
-			} else if tHistory[h].Time.Before(tFuture[f].Time) {
-				tRet = append(tRet, tHistory[h])
-				h++
-			} else {
-				tRet = append(tRet, tFuture[f])
-				h++
-				f++
-			}
+		ret.Forecast = c.parseDaily(resp.Hourly, resp.Daily, numdays, resp.tz)
+		if len(ret.Forecast) == 0 {
+			return ret, fmt.Errorf("The forecast.io response did not contain any hourly weather data")
 		}
-		ret.Forecast[0].Slots = tRet
+
+		// today is the date at the location, which is only known from the
+		// timezone of the forecast response
+		tHistory, err := c.fetchToday(ctx, location, time.Now().In(resp.tz))
+		if err != nil {
+			return ret, fmt.Errorf("Failed to fetch todays weather data: %v", err)
+		}
+		// the history starts at midnight, so together with the forecast it
+		// covers the whole day
+		ret.Forecast[0].Slots = forecastMergeSlots(tHistory, ret.Forecast[0].Slots)
+		iface.SummarizeDay(&ret.Forecast[0])
 	}
-	return ret
+	return ret, nil
 }
 
 func init() {
-	iface.AllBackends["forecast.io"] = &forecastConfig{}
+	iface.AllBackends["forecast.io"] = &forecastConfig{host: forecastHost, client: forecastClient}
 }
@@ -0,0 +1,561 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func forecastTestFloat(f float32) *float32 {
+	return &f
+}
+
+func forecastTestTime(t time.Time) *int64 {
+	u := t.Unix()
+	return &u
+}
+
+func forecastTestConfig(t *testing.T) *forecastConfig {
+	c := &forecastConfig{host: forecastHost, client: forecastClient, apiKey: "secret", units: "ca", lang: "en", extend: true}
+	if err := c.prepare(); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	return c
+}
+
+// forecastTestResponse returns a response in the timezone tz with the current
+// conditions at now and an hourly data point with the given temperature for
+// every hour after midnight.
+func forecastTestResponse(t *testing.T, tz *time.Location, now time.Time, temps map[int]float32) []byte {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, tz)
+	var hours []map[string]interface{}
+	for h := 0; h < 24; h++ {
+		if temp, ok := temps[h]; ok {
+			hours = append(hours, map[string]interface{}{"time": midnight.Add(time.Duration(h) * time.Hour).Unix(), "icon": "cloudy", "temperature": temp})
+		}
+	}
+	b, err := json.Marshal(map[string]interface{}{
+		"latitude":  35.68,
+		"longitude": 139.69,
+		"timezone":  tz.String(),
+		"flags":     map[string]string{"units": "si"},
+		"currently": map[string]interface{}{"time": now.Unix(), "icon": "clear-day", "windSpeed": 10},
+		"hourly":    map[string]interface{}{"data": hours},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestForecastParseCond(t *testing.T) {
+	c := forecastTestConfig(t)
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		dp   forecastDataPoint
+		code iface.WeatherCode
+	}{
+		{"clear", forecastDataPoint{Icon: "clear-day"}, iface.CodeSunny},
+		{"unknown icon", forecastDataPoint{Icon: "meteor-shower"}, iface.CodeUnknown},
+		{"light rain", forecastDataPoint{Icon: "rain", PrecipIntensity: forecastTestFloat(1)}, iface.CodeLightRain},
+		{"heavy rain", forecastDataPoint{Icon: "rain", PrecipIntensity: forecastTestFloat(5)}, iface.CodeHeavyRain},
+	}
+	for _, tt := range tests {
+		tt.dp.Time = forecastTestTime(now)
+		cond, err := c.parseCond(tt.dp, time.UTC)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if cond.Code != tt.code {
+			t.Errorf("%s: got code %s, want %s", tt.name, cond.Code.Name(), tt.code.Name())
+		}
+	}
+
+	cond, err := c.parseCond(forecastDataPoint{
+		Time:            forecastTestTime(now),
+		PrecipIntensity: forecastTestFloat(2),
+		Visibility:      forecastTestFloat(8),
+		Humidity:        forecastTestFloat(0.46),
+	}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cond.Time.Equal(now) {
+		t.Errorf("got time %v, want %v", cond.Time, now)
+	}
+	if cond.PrecipM == nil || *cond.PrecipM != 0.002 {
+		t.Errorf("got precipitation %v, want 0.002 m", cond.PrecipM)
+	}
+	if cond.VisibleDistM == nil || *cond.VisibleDistM != 8000 {
+		t.Errorf("got visibility %v, want 8000 m", cond.VisibleDistM)
+	}
+	if cond.Humidity == nil || *cond.Humidity != 46 {
+		t.Errorf("got humidity %v, want 46", cond.Humidity)
+	}
+	if cond.TempC != nil {
+		t.Errorf("got temperature %v, want it unset", *cond.TempC)
+	}
+
+	if _, err := c.parseCond(forecastDataPoint{}, time.UTC); err == nil {
+		t.Error("got no error for a data point without time")
+	}
+}
+
+func TestForecastParseDaily(t *testing.T) {
+	c := forecastTestConfig(t)
+
+	start := time.Date(2020, 6, 1, 22, 0, 0, 0, time.UTC)
+	var hours forecastDataBlock
+	for h := 0; h < 4; h++ {
+		hours.Data = append(hours.Data, forecastDataPoint{
+			Time:        forecastTestTime(start.Add(time.Duration(h) * time.Hour)),
+			Icon:        "cloudy",
+			Temperature: forecastTestFloat(float32(20 + h)),
+		})
+	}
+	days := forecastDataBlock{Data: []forecastDataPoint{{
+		Time:        forecastTestTime(time.Date(2020, 6, 2, 0, 0, 0, 0, time.UTC)),
+		SunriseTime: forecastTestTime(time.Date(2020, 6, 2, 4, 25, 0, 0, time.UTC)),
+	}}}
+
+	forecast := c.parseDaily(hours, days, 3, time.UTC)
+	if len(forecast) != 2 {
+		t.Fatalf("got %d days, want 2", len(forecast))
+	}
+	for i, want := range []int{1, 2} {
+		if d := forecast[i].Date.Day(); d != want {
+			t.Errorf("day %d: got date %d, want %d", i, d, want)
+		}
+		if len(forecast[i].Slots) != 2 {
+			t.Errorf("day %d: got %d slots, want 2", i, len(forecast[i].Slots))
+		}
+	}
+	if forecast[0].MaxtempC == nil || *forecast[0].MaxtempC != 21 {
+		t.Errorf("got maximum temperature %v, want 21", forecast[0].MaxtempC)
+	}
+	if !forecast[0].Astronomy.Sunrise.IsZero() {
+		t.Error("got the sunrise of the second day for the first one")
+	}
+	if sr := forecast[1].Astronomy.Sunrise; sr.Hour() != 4 || sr.Minute() != 25 {
+		t.Errorf("got sunrise %v, want 04:25", sr)
+	}
+
+	if forecast := c.parseDaily(hours, days, 1, time.UTC); len(forecast) != 1 {
+		t.Errorf("got %d days, want them limited to 1", len(forecast))
+	}
+	if forecast := c.parseDaily(forecastDataBlock{}, days, 3, time.UTC); len(forecast) != 0 {
+		t.Errorf("got %d days for empty hourly data, want none", len(forecast))
+	}
+}
+
+// TestForecastFetch serves the forecast and the history of today from a test
+// server and checks that both are merged into the first day.
+func TestForecastFetch(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+	now := time.Now().In(tokyo)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, tokyo)
+
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if strings.Contains(r.URL.Path, "T00:00:00") {
+			w.Write(forecastTestResponse(t, tokyo, now, map[int]float32{0: 10, 1: 11}))
+		} else {
+			w.Write(forecastTestResponse(t, tokyo, now, map[int]float32{1: 21, 2: 22}))
+		}
+	}))
+	defer srv.Close()
+
+	c := &forecastConfig{host: srv.URL, client: srv.Client(), apiKey: "secret", units: "si", lang: "en"}
+	r, err := c.Fetch(context.Background(), "35.68,139.69", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Errorf("got requests %v, want the forecast and the history of today", paths)
+	}
+
+	if loc := r.Current.Time.Location(); loc.String() != "Asia/Tokyo" {
+		t.Errorf("got current time in %s, want Asia/Tokyo", loc)
+	}
+	if r.Current.WindspeedKmph == nil || *r.Current.WindspeedKmph != 36 {
+		t.Errorf("got wind speed %v, want 36 km/h converted from m/s", r.Current.WindspeedKmph)
+	}
+
+	if len(r.Forecast) != 1 {
+		t.Fatalf("got %d days, want 1", len(r.Forecast))
+	}
+	slots := r.Forecast[0].Slots
+	wantTemps := []float32{10, 21, 22}
+	if len(slots) != len(wantTemps) {
+		t.Fatalf("got %d slots, want the history and forecast merged into %d", len(slots), len(wantTemps))
+	}
+	for i, want := range wantTemps {
+		if !slots[i].Time.Equal(midnight.Add(time.Duration(i) * time.Hour)) {
+			t.Errorf("slot %d: got time %v", i, slots[i].Time)
+		}
+		if slots[i].TempC == nil || *slots[i].TempC != want {
+			t.Errorf("slot %d: got temperature %v, want %v", i, slots[i].TempC, want)
+		}
+	}
+	if min := r.Forecast[0].MintempC; min == nil || *min != 10 {
+		t.Errorf("got minimum temperature %v, want 10 from the history", min)
+	}
+}
+
+// TestForecastFixture checks that -forecast-fixture reads the response from a
+// file without an api key or requests.
+func TestForecastFixture(t *testing.T) {
+	now := time.Now().In(time.UTC)
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := ioutil.WriteFile(path, forecastTestResponse(t, time.UTC, now, map[int]float32{now.Hour(): 15}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &forecastConfig{host: "http://127.0.0.1:0", client: forecastClient, units: "ca", lang: "en", fixture: path}
+	r, err := c.Fetch(context.Background(), "35.68,139.69", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Forecast) != 1 || len(r.Forecast[0].Slots) != 1 {
+		t.Fatalf("got forecast %+v, want one day with one slot", r.Forecast)
+	}
+	if temp := r.Forecast[0].Slots[0].TempC; temp == nil || *temp != 15 {
+		t.Errorf("got temperature %v, want 15", temp)
+	}
+
+	c.fixture = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := c.Fetch(context.Background(), "35.68,139.69", 1); err == nil {
+		t.Error("got no error for a missing fixture")
+	}
+}
+
+func TestForecastPercent(t *testing.T) {
+	tests := []struct {
+		in   float32
+		want int
+	}{
+		{0, 0},
+		{0.456, 46},
+		{0.994, 99},
+		{1.02, 100},
+		{-0.01, 0},
+	}
+	for _, tt := range tests {
+		if got := forecastPercent(&tt.in); got == nil || *got != tt.want {
+			t.Errorf("forecastPercent(%v) = %v, want %d", tt.in, got, tt.want)
+		}
+	}
+	if got := forecastPercent(nil); got != nil {
+		t.Errorf("forecastPercent(nil) = %d, want nil", *got)
+	}
+
+	c := forecastTestConfig(t)
+	cond, err := c.parseCond(forecastDataPoint{
+		Time:       forecastTestTime(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)),
+		PrecipProb: forecastTestFloat(1.02),
+		Humidity:   forecastTestFloat(1.1),
+		CloudCover: forecastTestFloat(-0.2),
+	}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, p := range map[string]struct {
+		got  *int
+		want int
+	}{
+		"chance of rain": {cond.ChanceOfRainPercent, 100},
+		"humidity":       {cond.Humidity, 100},
+		"cloud cover":    {cond.CloudCoverPercent, 0},
+	} {
+		if p.got == nil || *p.got != p.want {
+			t.Errorf("got %s %v, want it clamped to %d", name, p.got, p.want)
+		}
+	}
+}
+
+// TestForecastFetchToday checks that the history of today is requested for
+// the date at the location. The local timezone is 26 hours behind the one of
+// the location, so the local date is always a different one.
+func TestForecastFetchToday(t *testing.T) {
+	far, err := time.LoadLocation("Pacific/Kiritimati")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+	local, err := time.LoadLocation("Etc/GMT+12")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+	defer func(l *time.Location) { time.Local = l }(time.Local)
+	time.Local = local
+
+	now := time.Now().In(far)
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Write(forecastTestResponse(t, far, now, map[int]float32{0: 10, 23: 11}))
+	}))
+	defer srv.Close()
+
+	c := &forecastConfig{host: srv.URL, client: srv.Client(), apiKey: "secret", units: "si", lang: "en"}
+	if _, err := c.Fetch(context.Background(), "1.87,-157.4", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "/forecast/secret/1.87,-157.4," + now.Format("2006-01-02") + "T00:00:00"
+	found := false
+	for _, p := range paths {
+		found = found || p == want
+	}
+	if !found {
+		t.Errorf("got requests %v, want one for %s", paths, want)
+	}
+}
+
+func TestForecastNewCodes(t *testing.T) {
+	c := forecastTestConfig(t)
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		dp   forecastDataPoint
+		code iface.WeatherCode
+	}{
+		{"hail icon", forecastDataPoint{Icon: "hail"}, iface.CodeHail},
+		{"hail as sleet icon", forecastDataPoint{Icon: "sleet", PrecipType: "hail"}, iface.CodeHail},
+		{"freezing rain", forecastDataPoint{Icon: "rain", Temperature: forecastTestFloat(-1)}, iface.CodeFreezingRain},
+		{"rain above freezing", forecastDataPoint{Icon: "rain", Temperature: forecastTestFloat(1)}, iface.CodeLightRain},
+		{"snow as rain icon", forecastDataPoint{Icon: "rain", PrecipType: "snow"}, iface.CodeLightSnow},
+		{"tornado", forecastDataPoint{Icon: "tornado"}, iface.CodeTornado},
+	}
+	for _, tt := range tests {
+		tt.dp.Time = forecastTestTime(now)
+		cond, err := c.parseCond(tt.dp, time.UTC)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if cond.Code != tt.code {
+			t.Errorf("%s: got code %s, want %s", tt.name, cond.Code.Name(), tt.code.Name())
+		}
+	}
+}
+
+func TestForecastMergeSlots(t *testing.T) {
+	at := func(src string, hours ...int) []iface.Cond {
+		var ret []iface.Cond
+		for _, h := range hours {
+			ret = append(ret, iface.Cond{Time: time.Date(2020, 6, 1, h, 0, 0, 0, time.UTC), Desc: src})
+		}
+		return ret
+	}
+	join := func(parts ...[]iface.Cond) (ret []iface.Cond) {
+		for _, p := range parts {
+			ret = append(ret, p...)
+		}
+		return ret
+	}
+
+	tests := []struct {
+		name            string
+		history, future []iface.Cond
+		want            []iface.Cond
+	}{
+		{"equal", at("h", 0, 1), at("f", 0, 1), at("f", 0, 1)},
+		{"interleaved", at("h", 0, 2, 4), at("f", 1, 3), join(at("h", 0), at("f", 1), at("h", 2), at("f", 3), at("h", 4))},
+		{"disjoint", at("h", 0, 1), at("f", 2, 3), join(at("h", 0, 1), at("f", 2, 3))},
+		{"overlapping", at("h", 0, 1, 2), at("f", 2, 3), join(at("h", 0, 1), at("f", 2, 3))},
+		{"no history", nil, at("f", 1), at("f", 1)},
+		{"no future", at("h", 1), nil, at("h", 1)},
+	}
+	for _, tt := range tests {
+		got := forecastMergeSlots(tt.history, tt.future)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d slots, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i := range tt.want {
+			if !got[i].Time.Equal(tt.want[i].Time) || got[i].Desc != tt.want[i].Desc {
+				t.Errorf("%s: slot %d: got %d:00 from %s, want %d:00 from %s", tt.name, i, got[i].Time.Hour(), got[i].Desc, tt.want[i].Time.Hour(), tt.want[i].Desc)
+			}
+		}
+	}
+}
+
+func TestForecastRounding(t *testing.T) {
+	c := forecastTestConfig(t)
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		bearing, humidity, uv float32
+		dir, percent, index   int
+	}{
+		{359.6, 0.995, 2.5, 0, 100, 3},
+		{179.9, 0.994, 0.4, 180, 99, 0},
+		{0.4, 0.005, 7.49, 0, 1, 7},
+	}
+	for _, tt := range tests {
+		cond, err := c.parseCond(forecastDataPoint{
+			Time:        forecastTestTime(now),
+			WindBearing: forecastTestFloat(tt.bearing),
+			Humidity:    forecastTestFloat(tt.humidity),
+			UVIndex:     forecastTestFloat(tt.uv),
+		}, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cond.WinddirDegree == nil || *cond.WinddirDegree != tt.dir {
+			t.Errorf("wind bearing %v: got %v, want %d", tt.bearing, cond.WinddirDegree, tt.dir)
+		}
+		if cond.Humidity == nil || *cond.Humidity != tt.percent {
+			t.Errorf("humidity %v: got %v, want %d", tt.humidity, cond.Humidity, tt.percent)
+		}
+		if cond.UVIndex == nil || *cond.UVIndex != tt.index {
+			t.Errorf("uv index %v: got %v, want %d", tt.uv, cond.UVIndex, tt.index)
+		}
+	}
+}
+
+func TestForecastParseDailyOrder(t *testing.T) {
+	c := forecastTestConfig(t)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+
+	hourly := func(start time.Time, order ...int) forecastDataBlock {
+		var ret forecastDataBlock
+		for _, h := range order {
+			ret.Data = append(ret.Data, forecastDataPoint{
+				Time:        forecastTestTime(start.Add(time.Duration(h) * time.Hour)),
+				Icon:        "cloudy",
+				Temperature: forecastTestFloat(float32(h)),
+			})
+		}
+		return ret
+	}
+
+	// all hours are on the same day in UTC, but on two days in Tokyo, and
+	// the data points are shuffled as returned by some mirrors
+	forecast := c.parseDaily(hourly(time.Date(2020, 6, 1, 22, 0, 0, 0, tokyo), 3, 1, 0, 2), forecastDataBlock{}, 3, tokyo)
+	if len(forecast) != 2 {
+		t.Fatalf("got %d days, want 2", len(forecast))
+	}
+	for i, want := range []int{1, 2} {
+		if d := forecast[i].Date.Day(); d != want {
+			t.Errorf("day %d: got date %d, want %d", i, d, want)
+		}
+		if s := forecast[i].Slots; len(s) != 2 || s[0].Time.After(s[1].Time) {
+			t.Errorf("day %d: got slots %v, want two sorted ones", i, s)
+		}
+	}
+
+	// the data of the rest of June is missing, so the 1st of June is followed
+	// by the 1st of July with the same day of the month
+	gapped := hourly(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC), 0, 24*30)
+	forecast = c.parseDaily(gapped, forecastDataBlock{}, 3, time.UTC)
+	if len(forecast) != 2 {
+		t.Fatalf("got %d days, want the month boundary to split them into 2", len(forecast))
+	}
+	if m := forecast[1].Date.Month(); m != time.July {
+		t.Errorf("got month %s for the second day, want July", m)
+	}
+}
+
+// TestForecastTimeout checks that a server which does not answer in time makes
+// Fetch fail with a descriptive error instead of hanging.
+func TestForecastTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := &forecastConfig{host: srv.URL, client: srv.Client(), apiKey: "secret", units: "ca", lang: "en", timeout: 50 * time.Millisecond}
+	start := time.Now()
+	_, err := c.Fetch(context.Background(), "35.68,139.69", 1)
+	if err == nil {
+		t.Fatal("got no error from a server which does not answer")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Fetch returned after %v, want it to give up after the timeout", elapsed)
+	}
+	if !strings.Contains(err.Error(), "no response within 50ms") {
+		t.Errorf("got error %q, want it to mention the timeout", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("got error %q containing the api key", err)
+	}
+}
+
+func TestForecastUserAgent(t *testing.T) {
+	var mu sync.Mutex
+	var agents []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents = append(agents, r.Header.Get("User-Agent"))
+		mu.Unlock()
+		w.Write(forecastTestResponse(t, time.UTC, time.Now(), nil))
+	}))
+	defer srv.Close()
+
+	for _, tt := range []struct{ override, want string }{
+		{"", "wego/" + iface.Version() + " "},
+		{"my-script/1.0", "my-script/1.0"},
+	} {
+		agents = nil
+		c := &forecastConfig{host: srv.URL, client: srv.Client(), apiKey: "secret", units: "ca", lang: "en", userAgent: tt.override}
+		if _, err := c.Fetch(context.Background(), "35.68,139.69", 0); err != nil {
+			t.Fatal(err)
+		}
+		if len(agents) != 1 || !strings.HasPrefix(agents[0], tt.want) {
+			t.Errorf("override %q: got User-Agent %q, want %q", tt.override, agents, tt.want)
+		}
+	}
+}
+
+// TestForecastCancel checks that cancelling the context aborts the requests in
+// flight.
+func TestForecastCancel(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := &forecastConfig{host: srv.URL, client: srv.Client(), apiKey: "secret", units: "ca", lang: "en", retries: 3}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := c.Fetch(ctx, "35.68,139.69", 1); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("got error %v, want the cancellation", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Fetch returned after %v, want it aborted right away", elapsed)
+	}
+}
@@ -1,9 +1,9 @@
 package backends
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
-	"log"
 
 	"github.com/schachmat/wego/iface"
 )
@@ -18,21 +18,21 @@ func (c *jsnConfig) Setup() {
 // read it as json content to fill the data. The numdays argument will only work
 // to further limit the amount of days in the output. It obviously cannot
 // produce more data than is available in the file.
-func (c *jsnConfig) Fetch(loc string, numdays int) (ret iface.Data) {
+func (c *jsnConfig) Fetch(ctx context.Context, loc string, numdays int) (ret iface.Data, err error) {
 	b, err := ioutil.ReadFile(loc)
 	if err != nil {
-		log.Fatal(err)
+		return ret, err
 	}
 
 	err = json.Unmarshal(b, &ret)
 	if err != nil {
-		log.Fatal(err)
+		return ret, err
 	}
 
 	if len(ret.Forecast) > numdays {
 		ret.Forecast = ret.Forecast[:numdays]
 	}
-	return
+	return ret, nil
 }
 
 func init() {
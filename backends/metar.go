@@ -0,0 +1,267 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type metarConfig struct {
+	station string
+	debug   bool
+}
+
+type metarObservation struct {
+	IcaoID   string      `json:"icaoId"`
+	Name     string      `json:"name"`
+	ObsTime  *int64      `json:"obsTime"`
+	Temp     *float32    `json:"temp"`
+	Dewp     *float32    `json:"dewp"`
+	Wdir     interface{} `json:"wdir"`
+	Wspd     *float32    `json:"wspd"`
+	Wgst     *float32    `json:"wgst"`
+	Visib    interface{} `json:"visib"`
+	WxString string      `json:"wxString"`
+	RawOb    string      `json:"rawOb"`
+	Lat      *float32    `json:"lat"`
+	Lon      *float32    `json:"lon"`
+	Clouds   []struct {
+		Cover string `json:"cover"`
+	} `json:"clouds"`
+}
+
+const (
+	// see https://aviationweather.gov/data/api/
+	metarWuri = "https://aviationweather.gov/api/data/metar?ids=%s&format=json"
+
+	metarKnotsToKmph    = 1.852
+	metarStatuteMileToM = 1609.344
+)
+
+// metarNumber converts fields which are either a number or a string like "VRB"
+// (variable wind direction) or "10+" (visibility of at least 10 miles).
+func metarNumber(v interface{}) *float32 {
+	var ret float32
+	switch n := v.(type) {
+	case float64:
+		ret = float32(n)
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSuffix(n, "+"), 32)
+		if err != nil {
+			return nil
+		}
+		ret = float32(f)
+	default:
+		return nil
+	}
+	return &ret
+}
+
+// metarParseCode derives the weather code from the present weather groups,
+// falling back to the most significant sky cover group.
+func metarParseCode(wx string, clouds []string) iface.WeatherCode {
+	has := func(s string) bool { return strings.Contains(wx, s) }
+	heavy := has("+")
+	showers := has("SH")
+
+	switch {
+	case has("TS"):
+		if has("SN") {
+			return iface.CodeThunderySnowShowers
+		} else if heavy {
+			return iface.CodeThunderyHeavyRain
+		}
+		return iface.CodeThunderyShowers
+	case has("FZ") || has("PL") || has("GR") || has("GS") || has("IC") || (has("RA") && has("SN")):
+		if showers {
+			return iface.CodeLightSleetShowers
+		}
+		return iface.CodeLightSleet
+	case has("SN") || has("SG"):
+		if showers && heavy {
+			return iface.CodeHeavySnowShowers
+		} else if showers {
+			return iface.CodeLightSnowShowers
+		} else if heavy {
+			return iface.CodeHeavySnow
+		}
+		return iface.CodeLightSnow
+	case has("RA") || has("DZ") || has("UP"):
+		if showers && heavy {
+			return iface.CodeHeavyShowers
+		} else if showers {
+			return iface.CodeLightShowers
+		} else if heavy {
+			return iface.CodeHeavyRain
+		}
+		return iface.CodeLightRain
+	case has("FG") || has("BR") || has("HZ") || has("FU"):
+		return iface.CodeFog
+	}
+
+	// sky cover groups ordered by significance
+	covers := []struct {
+		cover string
+		code  iface.WeatherCode
+	}{
+		{"CAVOK", iface.CodeSunny},
+		{"SKC", iface.CodeSunny},
+		{"CLR", iface.CodeSunny},
+		{"NSC", iface.CodeSunny},
+		{"NCD", iface.CodeSunny},
+		{"FEW", iface.CodeSunny},
+		{"SCT", iface.CodePartlyCloudy},
+		{"BKN", iface.CodeCloudy},
+		{"OVC", iface.CodeCloudy},
+		{"OVX", iface.CodeFog},
+	}
+	ret, rank := iface.CodeUnknown, -1
+	for _, cover := range clouds {
+		for i, candidate := range covers {
+			if candidate.cover == cover && i > rank {
+				ret, rank = candidate.code, i
+			}
+		}
+	}
+	return ret
+}
+
+func (c *metarConfig) parseCond(ob metarObservation) (ret iface.Cond, err error) {
+	if ob.ObsTime == nil {
+		return iface.Cond{}, fmt.Errorf("The METAR did not provide an observation time")
+	}
+	ret.Time = time.Unix(*ob.ObsTime, 0).In(time.Local)
+
+	var clouds []string
+	for _, cl := range ob.Clouds {
+		clouds = append(clouds, cl.Cover)
+	}
+	ret.Code = metarParseCode(ob.WxString, clouds)
+	ret.Desc = ob.RawOb
+
+	ret.TempC = ob.Temp
+
+	// there is no dewpoint in iface.Cond, but we can derive the relative
+	// humidity from it with the magnus formula
+	if ob.Temp != nil && ob.Dewp != nil {
+		magnus := func(t float32) float64 { return math.Exp(17.625 * float64(t) / (243.04 + float64(t))) }
+		h := int(math.Round(100 * magnus(*ob.Dewp) / magnus(*ob.Temp)))
+		if h >= 0 && h <= 100 {
+			ret.Humidity = &h
+		}
+	}
+
+	if ob.Wspd != nil && *ob.Wspd >= 0 {
+		s := *ob.Wspd * metarKnotsToKmph
+		ret.WindspeedKmph = &s
+	}
+
+	if ob.Wgst != nil && *ob.Wgst >= 0 {
+		s := *ob.Wgst * metarKnotsToKmph
+		ret.WindGustKmph = &s
+	}
+
+	if d := metarNumber(ob.Wdir); d != nil && *d >= 0 {
+		p := int(*d) % 360
+		ret.WinddirDegree = &p
+	}
+
+	if v := metarNumber(ob.Visib); v != nil && *v >= 0 {
+		m := *v * metarStatuteMileToM
+		ret.VisibleDistM = &m
+	}
+
+	// the api does not provide the apparent temperature
+	ret.FeelsLikeC = iface.ApparentTempC(ret.TempC, ret.Humidity, ret.WindspeedKmph)
+
+	return ret, nil
+}
+
+func (c *metarConfig) fetch(ctx context.Context, url string) ([]metarObservation, error) {
+	res, err := iface.Get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get (%s): %v", url, err)
+	} else if res.StatusCode != 200 {
+		res.Body.Close()
+		return nil, fmt.Errorf("Unable to get (%s): http status %d", url, res.StatusCode)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read response body (%s): %v", url, err)
+	}
+
+	iface.Logf(iface.LogDebug, "Response (%s): %s", url, string(body))
+
+	var resp []metarObservation
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal response (%s): %v\nThe json body is: %s", url, err, string(body))
+	}
+	return resp, nil
+}
+
+// MaxDays is one day, metar only reports the current conditions.
+func (c *metarConfig) MaxDays() int {
+	return 1
+}
+
+func (c *metarConfig) Setup() {
+	flag.StringVar(&c.station, "metar-station", "", "metar backend: the ICAO `STATION` code to query, like EDDM")
+	flag.BoolVar(&c.debug, "metar-debug", false, "metar backend: print raw requests and responses, same as -log-level debug")
+}
+
+// Fetch returns the latest observation of the configured station, or of the
+// station given as location if none is configured. As METARs only describe the
+// current conditions, the forecast consists of a single day with one slot.
+func (c *metarConfig) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
+	var ret iface.Data
+
+	station := c.station
+	if station == "" {
+		station = location
+	}
+	station = strings.ToUpper(station)
+	if matched, err := regexp.MatchString(`^[A-Z0-9]{4}$`, station); !matched || err != nil {
+		return ret, fmt.Errorf("The metar backend only supports ICAO station codes like `EDDM`, not `%s`", station)
+	}
+
+	obs, err := c.fetch(ctx, fmt.Sprintf(metarWuri, station))
+	if err != nil {
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+	if len(obs) == 0 {
+		return ret, fmt.Errorf("No METAR available for station %s", station)
+	}
+
+	ret.Source = "metar"
+	ret.Location = station
+	if obs[0].Name != "" {
+		ret.Location = fmt.Sprintf("%s (%s)", obs[0].Name, station)
+	}
+	if obs[0].Lat != nil && obs[0].Lon != nil {
+		ret.GeoLoc = &iface.LatLon{Latitude: *obs[0].Lat, Longitude: *obs[0].Lon}
+	}
+
+	if ret.Current, err = c.parseCond(obs[0]); err != nil {
+		return ret, fmt.Errorf("Could not parse current weather condition: %v", err)
+	}
+
+	if numdays >= 1 {
+		ret.Forecast = []iface.Day{{Date: ret.Current.Time, Slots: []iface.Cond{ret.Current}}}
+	}
+	return ret, nil
+}
+
+func init() {
+	iface.AllBackends["metar"] = &metarConfig{}
+}
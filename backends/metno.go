@@ -0,0 +1,387 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type metnoConfig struct {
+	userAgent string
+	debug     bool
+}
+
+type metnoDetails struct {
+	AirTemperature           *float32 `json:"air_temperature"`
+	RelativeHumidity         *float32 `json:"relative_humidity"`
+	WindFromDirection        *float32 `json:"wind_from_direction"`
+	WindSpeed                *float32 `json:"wind_speed"`
+	WindSpeedOfGust          *float32 `json:"wind_speed_of_gust"`
+	PrecipitationAmount      *float32 `json:"precipitation_amount"`
+	ProbabilityOfPrecipation *float32 `json:"probability_of_precipitation"`
+}
+
+type metnoPeriod struct {
+	Summary struct {
+		SymbolCode string `json:"symbol_code"`
+	} `json:"summary"`
+	Details metnoDetails `json:"details"`
+}
+
+type metnoTimestep struct {
+	Time time.Time `json:"time"`
+	Data struct {
+		Instant struct {
+			Details metnoDetails `json:"details"`
+		} `json:"instant"`
+		Next1Hours  *metnoPeriod `json:"next_1_hours"`
+		Next6Hours  *metnoPeriod `json:"next_6_hours"`
+		Next12Hours *metnoPeriod `json:"next_12_hours"`
+	} `json:"data"`
+}
+
+type metnoResponse struct {
+	Geometry struct {
+		Coordinates []float32 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties struct {
+		Timeseries []metnoTimestep `json:"timeseries"`
+	} `json:"properties"`
+}
+
+// metnoCacheEntry is stored on disk between invocations, so the backend can
+// honor the Expires and Last-Modified headers as required by the met.no terms
+// of service.
+type metnoCacheEntry struct {
+	Expires      time.Time
+	LastModified string
+	Body         json.RawMessage
+}
+
+const (
+	// see https://api.met.no/weatherapi/locationforecast/2.0/documentation
+	metnoWuri = "https://api.met.no/weatherapi/locationforecast/2.0/complete?lat=%.4f&lon=%.4f"
+)
+
+func (c *metnoConfig) cacheFile(lat, lon float64) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "wego", fmt.Sprintf("metno_%.4f_%.4f.json", lat, lon))
+}
+
+func (c *metnoConfig) readCache(path string) (entry metnoCacheEntry) {
+	if path == "" {
+		return
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &entry); err != nil {
+		iface.Logf(iface.LogDebug, "Ignoring broken met.no cache file (%s): %v", path, err)
+	}
+	return
+}
+
+func (c *metnoConfig) writeCache(path string, entry metnoCacheEntry) {
+	if path == "" {
+		return
+	}
+	b, err := json.Marshal(entry)
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			err = ioutil.WriteFile(path, b, 0644)
+		}
+	}
+	if err != nil {
+		log.Printf("Unable to write met.no cache file (%s): %v", path, err)
+	}
+}
+
+func (c *metnoConfig) fetch(ctx context.Context, lat, lon float64) (*metnoResponse, error) {
+	url := fmt.Sprintf(metnoWuri, lat, lon)
+	if err := iface.CheckDryRun(url); err != nil {
+		return nil, err
+	}
+	path := c.cacheFile(lat, lon)
+	entry := c.readCache(path)
+
+	if len(entry.Body) == 0 || time.Now().After(entry.Expires) {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to create request (%s): %v", url, err)
+		}
+		req.Header.Set("User-Agent", c.userAgent)
+		if len(entry.Body) > 0 && entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+
+		start := time.Now()
+		res, err := iface.HTTPClient.Do(req)
+		iface.LogRequest(req, res, err, start)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get (%s): %v", url, err)
+		}
+		defer res.Body.Close()
+
+		switch res.StatusCode {
+		case http.StatusOK:
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to read response body (%s): %v", url, err)
+			}
+			entry.Body = body
+			entry.LastModified = res.Header.Get("Last-Modified")
+		case http.StatusNotModified:
+			iface.Logf(iface.LogInfo, "Response (%s) not modified, using cached data", url)
+		default:
+			return nil, fmt.Errorf("Unable to get (%s): http status %d", url, res.StatusCode)
+		}
+
+		entry.Expires, err = http.ParseTime(res.Header.Get("Expires"))
+		if err != nil {
+			entry.Expires = time.Now().Add(30 * time.Minute)
+		}
+		c.writeCache(path, entry)
+	}
+
+	iface.Logf(iface.LogDebug, "Response (%s): %s", url, string(entry.Body))
+
+	var resp metnoResponse
+	if err := json.Unmarshal(entry.Body, &resp); err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal response (%s): %v\nThe json body is: %s", url, err, string(entry.Body))
+	}
+	return &resp, nil
+}
+
+func (c *metnoConfig) parseCode(symbol string) (iface.WeatherCode, string) {
+	codemap := map[string]struct {
+		code iface.WeatherCode
+		desc string
+	}{
+		"clearsky":                     {iface.CodeSunny, "Clear sky"},
+		"fair":                         {iface.CodePartlyCloudy, "Fair"},
+		"partlycloudy":                 {iface.CodePartlyCloudy, "Partly cloudy"},
+		"cloudy":                       {iface.CodeCloudy, "Cloudy"},
+		"fog":                          {iface.CodeFog, "Fog"},
+		"lightrainshowers":             {iface.CodeLightShowers, "Light rain showers"},
+		"rainshowers":                  {iface.CodeLightShowers, "Rain showers"},
+		"heavyrainshowers":             {iface.CodeHeavyShowers, "Heavy rain showers"},
+		"lightrainshowersandthunder":   {iface.CodeThunderyShowers, "Light rain showers and thunder"},
+		"rainshowersandthunder":        {iface.CodeThunderyShowers, "Rain showers and thunder"},
+		"heavyrainshowersandthunder":   {iface.CodeThunderyHeavyRain, "Heavy rain showers and thunder"},
+		"lightsleetshowers":            {iface.CodeLightSleetShowers, "Light sleet showers"},
+		"sleetshowers":                 {iface.CodeLightSleetShowers, "Sleet showers"},
+		"heavysleetshowers":            {iface.CodeLightSleetShowers, "Heavy sleet showers"},
+		"lightssleetshowersandthunder": {iface.CodeThunderyShowers, "Light sleet showers and thunder"},
+		"sleetshowersandthunder":       {iface.CodeThunderyShowers, "Sleet showers and thunder"},
+		"heavysleetshowersandthunder":  {iface.CodeThunderyShowers, "Heavy sleet showers and thunder"},
+		"lightsnowshowers":             {iface.CodeLightSnowShowers, "Light snow showers"},
+		"snowshowers":                  {iface.CodeLightSnowShowers, "Snow showers"},
+		"heavysnowshowers":             {iface.CodeHeavySnowShowers, "Heavy snow showers"},
+		"lightssnowshowersandthunder":  {iface.CodeThunderySnowShowers, "Light snow showers and thunder"},
+		"snowshowersandthunder":        {iface.CodeThunderySnowShowers, "Snow showers and thunder"},
+		"heavysnowshowersandthunder":   {iface.CodeThunderySnowShowers, "Heavy snow showers and thunder"},
+		"lightrain":                    {iface.CodeLightRain, "Light rain"},
+		"rain":                         {iface.CodeLightRain, "Rain"},
+		"heavyrain":                    {iface.CodeHeavyRain, "Heavy rain"},
+		"lightrainandthunder":          {iface.CodeThunderyShowers, "Light rain and thunder"},
+		"rainandthunder":               {iface.CodeThunderyShowers, "Rain and thunder"},
+		"heavyrainandthunder":          {iface.CodeThunderyHeavyRain, "Heavy rain and thunder"},
+		"lightsleet":                   {iface.CodeLightSleet, "Light sleet"},
+		"sleet":                        {iface.CodeLightSleet, "Sleet"},
+		"heavysleet":                   {iface.CodeLightSleet, "Heavy sleet"},
+		"lightsleetandthunder":         {iface.CodeThunderyShowers, "Light sleet and thunder"},
+		"sleetandthunder":              {iface.CodeThunderyShowers, "Sleet and thunder"},
+		"heavysleetandthunder":         {iface.CodeThunderyShowers, "Heavy sleet and thunder"},
+		"lightsnow":                    {iface.CodeLightSnow, "Light snow"},
+		"snow":                         {iface.CodeLightSnow, "Snow"},
+		"heavysnow":                    {iface.CodeHeavySnow, "Heavy snow"},
+		"lightsnowandthunder":          {iface.CodeThunderySnowShowers, "Light snow and thunder"},
+		"snowandthunder":               {iface.CodeThunderySnowShowers, "Snow and thunder"},
+		"heavysnowandthunder":          {iface.CodeThunderySnowShowers, "Heavy snow and thunder"},
+	}
+
+	// symbol codes may carry a _day, _night or _polartwilight variant suffix
+	if i := strings.Index(symbol, "_"); i >= 0 {
+		symbol = symbol[:i]
+	}
+	if val, ok := codemap[symbol]; ok {
+		return val.code, val.desc
+	}
+	return iface.CodeUnknown, ""
+}
+
+func (c *metnoConfig) parseCond(step metnoTimestep) (ret iface.Cond, err error) {
+	if step.Time.IsZero() {
+		return iface.Cond{}, fmt.Errorf("The met.no response did not provide a time for the weather condition")
+	}
+	// the api does not provide the timezone of the location, so the times are
+	// kept in UTC instead of splitting the days at the local midnight of the
+	// machine running wego
+	ret.Time = step.Time.UTC()
+
+	// prefer the shortest period available for symbol and precipitation
+	var period *metnoPeriod
+	var hours float32
+	if p := step.Data.Next1Hours; p != nil {
+		period, hours = p, 1
+	} else if p := step.Data.Next6Hours; p != nil {
+		period, hours = p, 6
+	} else if p := step.Data.Next12Hours; p != nil {
+		period, hours = p, 12
+	}
+
+	ret.Code = iface.CodeUnknown
+	if period != nil {
+		ret.Code, ret.Desc = c.parseCode(period.Summary.SymbolCode)
+
+		if p := period.Details.PrecipitationAmount; p != nil && *p >= 0 {
+			m := *p / 1000 / hours
+			ret.PrecipM = &m
+		}
+
+		if p := period.Details.ProbabilityOfPrecipation; p != nil && *p >= 0 && *p <= 100 {
+			r := int(math.Round(float64(*p)))
+			ret.ChanceOfRainPercent = &r
+		}
+	}
+
+	d := step.Data.Instant.Details
+	ret.TempC = d.AirTemperature
+
+	if d.WindSpeed != nil && *d.WindSpeed >= 0 {
+		s := *d.WindSpeed * 3.6
+		ret.WindspeedKmph = &s
+	}
+
+	if d.WindSpeedOfGust != nil && *d.WindSpeedOfGust >= 0 {
+		s := *d.WindSpeedOfGust * 3.6
+		ret.WindGustKmph = &s
+	}
+
+	if d.WindFromDirection != nil && *d.WindFromDirection >= 0 {
+		p := int(math.Round(float64(*d.WindFromDirection))) % 360
+		ret.WinddirDegree = &p
+	}
+
+	if d.RelativeHumidity != nil && *d.RelativeHumidity >= 0 && *d.RelativeHumidity <= 100 {
+		p := int(math.Round(float64(*d.RelativeHumidity)))
+		ret.Humidity = &p
+	}
+
+	// the api does not provide the apparent temperature
+	ret.FeelsLikeC = iface.ApparentTempC(ret.TempC, ret.Humidity, ret.WindspeedKmph)
+
+	return ret, nil
+}
+
+func (c *metnoConfig) parseDaily(series []metnoTimestep, numdays int) []iface.Day {
+	var forecast []iface.Day
+	var day *iface.Day
+
+	for _, step := range series {
+		slot, err := c.parseCond(step)
+		if err != nil {
+			log.Println("Error parsing hourly weather condition:", err)
+			continue
+		}
+
+		if day != nil && !forecastSameDate(day.Date, slot.Time) {
+			forecast = append(forecast, *day)
+			if len(forecast) >= numdays {
+				return forecast
+			}
+			day = nil
+		}
+		if day == nil {
+			day = new(iface.Day)
+			day.Date = slot.Time
+		}
+
+		day.Slots = append(day.Slots, slot)
+	}
+
+	if day != nil && len(forecast) < numdays {
+		forecast = append(forecast, *day)
+	}
+	return forecast
+}
+
+// MaxDays is the nine days covered by the locationforecast.
+func (c *metnoConfig) MaxDays() int {
+	return 9
+}
+
+func (c *metnoConfig) Setup() {
+	flag.StringVar(&c.userAgent, "metno-user-agent", "wego https://github.com/schachmat/wego", "metno backend: the `USERAGENT` identifying you to api.met.no, should contain contact information")
+	flag.BoolVar(&c.debug, "metno-debug", false, "metno backend: print raw requests and responses, same as -log-level debug")
+}
+
+// CheckConfig reports an error if the User-Agent is missing.
+func (c *metnoConfig) CheckConfig() error {
+	if len(c.userAgent) == 0 {
+		return fmt.Errorf("No met.no User-Agent specified.\nThe met.no terms of service require an identifying User-Agent, see https://api.met.no/doc/TermsOfService")
+	}
+	return nil
+}
+
+func (c *metnoConfig) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
+	var ret iface.Data
+
+	if err := c.CheckConfig(); err != nil {
+		return ret, err
+	}
+	coords, err := iface.ResolveLatLon(ctx, location)
+	if err != nil {
+		return ret, fmt.Errorf("Could not find the location `%s`: %v\nTry a place name or `59.913,10.739` for example to get a forecast for Oslo", location, err)
+	}
+	location = coords
+	s := strings.Split(location, ",")
+	lat, _ := strconv.ParseFloat(s[0], 64)
+	lon, _ := strconv.ParseFloat(s[1], 64)
+
+	resp, err := c.fetch(ctx, lat, lon)
+	if err != nil {
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+
+	ret.Attribution = "Data from MET Norway"
+	ret.Source = "metno"
+	ret.Location = fmt.Sprintf("%.4f,%.4f", lat, lon)
+	if coords := resp.Geometry.Coordinates; len(coords) >= 2 {
+		ret.GeoLoc = &iface.LatLon{Latitude: coords[1], Longitude: coords[0]}
+	}
+
+	series := resp.Properties.Timeseries
+	if len(series) == 0 {
+		return ret, fmt.Errorf("The met.no response did not contain any weather data")
+	}
+
+	if ret.Current, err = c.parseCond(series[0]); err != nil {
+		return ret, fmt.Errorf("Could not parse current weather condition: %v", err)
+	}
+
+	if numdays >= 1 {
+		ret.Forecast = c.parseDaily(series, numdays)
+	}
+	return ret, nil
+}
+
+func init() {
+	iface.AllBackends["metno"] = &metnoConfig{}
+}
@@ -0,0 +1,40 @@
+package backends
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetnoParseDaily(t *testing.T) {
+	local := time.Local
+	defer func() { time.Local = local }()
+	time.Local = time.FixedZone("UTC-10", -10*60*60)
+
+	c := &metnoConfig{}
+	var series []metnoTimestep
+	// the same day of different months must not end up in the same day
+	for _, ts := range []string{"2020-05-31T18:00:00Z", "2020-05-31T23:00:00Z", "2020-06-01T00:00:00Z", "2020-06-01T12:00:00Z", "2020-07-01T06:00:00Z"} {
+		var step metnoTimestep
+		var err error
+		if step.Time, err = time.Parse(time.RFC3339, ts); err != nil {
+			t.Fatal(err)
+		}
+		series = append(series, step)
+	}
+
+	days := c.parseDaily(series, 9)
+	if len(days) != 3 {
+		t.Fatalf("got %d days, want 3", len(days))
+	}
+	for i, want := range []struct {
+		date  string
+		slots int
+	}{{"2020-05-31", 2}, {"2020-06-01", 2}, {"2020-07-01", 1}} {
+		if got := days[i].Date.Format("2006-01-02"); got != want.date || len(days[i].Slots) != want.slots {
+			t.Errorf("day %d: got %s with %d slots, want %s with %d", i, got, len(days[i].Slots), want.date, want.slots)
+		}
+		if days[i].Date.Location() != time.UTC {
+			t.Errorf("day %d is in %v, want UTC independent of the local timezone", i, days[i].Date.Location())
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type nwsConfig struct {
+	userAgent string
+	debug     bool
+	client    http.Client
+}
+
+type nwsPointsResponse struct {
+	Properties struct {
+		ForecastHourly   string `json:"forecastHourly"`
+		TimeZone         string `json:"timeZone"`
+		RelativeLocation struct {
+			Properties struct {
+				City  string `json:"city"`
+				State string `json:"state"`
+			} `json:"properties"`
+		} `json:"relativeLocation"`
+	} `json:"properties"`
+}
+
+type nwsValue struct {
+	Value *float32 `json:"value"`
+}
+
+type nwsPeriod struct {
+	StartTime                  time.Time `json:"startTime"`
+	IsDaytime                  bool      `json:"isDaytime"`
+	Temperature                *float32  `json:"temperature"`
+	TemperatureUnit            string    `json:"temperatureUnit"`
+	WindSpeed                  string    `json:"windSpeed"`
+	WindDirection              string    `json:"windDirection"`
+	ShortForecast              string    `json:"shortForecast"`
+	ProbabilityOfPrecipitation nwsValue  `json:"probabilityOfPrecipitation"`
+	RelativeHumidity           nwsValue  `json:"relativeHumidity"`
+}
+
+type nwsForecastResponse struct {
+	Properties struct {
+		Periods []nwsPeriod `json:"periods"`
+	} `json:"properties"`
+}
+
+const (
+	// see https://www.weather.gov/documentation/services-web-api
+	nwsPuri = "https://api.weather.gov/points/%.4f,%.4f"
+)
+
+// compassToDegree translates a compass point like "NNE" into the direction in
+// degrees. Unknown directions return false.
+func compassToDegree(dir string) (int, bool) {
+	points := []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+	dir = strings.ToUpper(strings.TrimSpace(dir))
+	for i, p := range points {
+		if p == dir {
+			return int(float32(i) * 22.5), true
+		}
+	}
+	return 0, false
+}
+
+// nwsParseCode guesses the weather code from the shortForecast text. At night
+// showers are mapped to their non-shower counterparts, because the shower
+// codes imply the sun shining through the clouds.
+func nwsParseCode(forecast string, daytime bool) iface.WeatherCode {
+	f := strings.ToLower(forecast)
+	has := func(words ...string) bool {
+		for _, w := range words {
+			if strings.Contains(f, w) {
+				return true
+			}
+		}
+		return false
+	}
+	heavy := has("heavy", "blizzard")
+	showers := daytime && has("shower")
+
+	switch {
+	case has("thunder", "t-storm"):
+		if has("snow") {
+			return iface.CodeThunderySnowShowers
+		} else if heavy {
+			return iface.CodeThunderyHeavyRain
+		}
+		return iface.CodeThunderyShowers
+	case has("sleet", "freezing", "ice"):
+		if showers {
+			return iface.CodeLightSleetShowers
+		}
+		return iface.CodeLightSleet
+	case has("snow", "flurries", "blizzard"):
+		if showers && heavy {
+			return iface.CodeHeavySnowShowers
+		} else if showers {
+			return iface.CodeLightSnowShowers
+		} else if heavy {
+			return iface.CodeHeavySnow
+		}
+		return iface.CodeLightSnow
+	case has("rain", "drizzle", "shower"):
+		if showers && heavy {
+			return iface.CodeHeavyShowers
+		} else if showers {
+			return iface.CodeLightShowers
+		} else if heavy {
+			return iface.CodeHeavyRain
+		}
+		return iface.CodeLightRain
+	case has("fog", "haze", "smoke", "dust"):
+		return iface.CodeFog
+	case has("partly", "mostly sunny", "mostly clear"):
+		return iface.CodePartlyCloudy
+	case has("mostly cloudy"):
+		return iface.CodeCloudy
+	case has("cloudy", "overcast"):
+		return iface.CodeVeryCloudy
+	case has("sunny", "clear", "fair"):
+		return iface.CodeSunny
+	}
+	return iface.CodeUnknown
+}
+
+func (c *nwsConfig) fetch(ctx context.Context, url string, out interface{}) error {
+	if err := iface.CheckDryRun(url); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("Unable to create request (%s): %v", url, err)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept", "application/geo+json")
+
+	start := time.Now()
+	res, err := c.client.Do(req)
+	iface.LogRequest(req, res, err, start)
+	if err != nil {
+		return fmt.Errorf("Unable to get (%s): %v", url, err)
+	} else if res.StatusCode != 200 {
+		res.Body.Close()
+		return fmt.Errorf("Unable to get (%s): http status %d", url, res.StatusCode)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("Unable to read response body (%s): %v", url, err)
+	}
+
+	iface.Logf(iface.LogDebug, "Response (%s): %s", url, string(body))
+
+	if err = json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("Unable to unmarshal response (%s): %v\nThe json body is: %s", url, err, string(body))
+	}
+	return nil
+}
+
+func (c *nwsConfig) parseCond(p nwsPeriod, tz *time.Location) (ret iface.Cond, err error) {
+	if p.StartTime.IsZero() {
+		return iface.Cond{}, fmt.Errorf("The weather.gov response did not provide a time for the weather condition")
+	}
+	// without the timezone the offset of the response is kept, which is the
+	// one of the location as well
+	ret.Time = p.StartTime
+	if tz != nil {
+		ret.Time = ret.Time.In(tz)
+	}
+
+	ret.Code = nwsParseCode(p.ShortForecast, p.IsDaytime)
+	ret.Desc = p.ShortForecast
+
+	if p.Temperature != nil {
+		t := *p.Temperature
+		if p.TemperatureUnit != "C" {
+			t = (t - 32) / 1.8
+		}
+		ret.TempC = &t
+	}
+
+	// windSpeed is either a single value like "10 mph" or a range like
+	// "5 to 10 mph", in which case we use the upper bound.
+	if speeds := regexp.MustCompile(`[0-9]+`).FindAllString(p.WindSpeed, -1); len(speeds) > 0 {
+		if s, err := strconv.ParseFloat(speeds[len(speeds)-1], 32); err == nil {
+			kmph := float32(s)
+			if !strings.Contains(p.WindSpeed, "km/h") {
+				kmph *= 1.609
+			}
+			ret.WindspeedKmph = &kmph
+		}
+	}
+
+	if deg, ok := compassToDegree(p.WindDirection); ok {
+		ret.WinddirDegree = &deg
+	}
+
+	if v := p.ProbabilityOfPrecipitation.Value; v != nil && *v >= 0 && *v <= 100 {
+		r := int(math.Round(float64(*v)))
+		ret.ChanceOfRainPercent = &r
+	}
+
+	if v := p.RelativeHumidity.Value; v != nil && *v >= 0 && *v <= 100 {
+		h := int(math.Round(float64(*v)))
+		ret.Humidity = &h
+	}
+
+	// the api does not provide the apparent temperature
+	ret.FeelsLikeC = iface.ApparentTempC(ret.TempC, ret.Humidity, ret.WindspeedKmph)
+
+	return ret, nil
+}
+
+func (c *nwsConfig) parseDaily(periods []nwsPeriod, numdays int, tz *time.Location) []iface.Day {
+	var forecast []iface.Day
+	var day *iface.Day
+
+	for _, period := range periods {
+		slot, err := c.parseCond(period, tz)
+		if err != nil {
+			log.Println("Error parsing hourly weather condition:", err)
+			continue
+		}
+
+		if day != nil && !forecastSameDate(day.Date, slot.Time) {
+			forecast = append(forecast, *day)
+			if len(forecast) >= numdays {
+				return forecast
+			}
+			day = nil
+		}
+		if day == nil {
+			day = new(iface.Day)
+			day.Date = slot.Time
+		}
+
+		day.Slots = append(day.Slots, slot)
+	}
+
+	if day != nil && len(forecast) < numdays {
+		forecast = append(forecast, *day)
+	}
+	return forecast
+}
+
+// MaxDays is the seven days covered by the hourly forecast.
+func (c *nwsConfig) MaxDays() int {
+	return 7
+}
+
+func (c *nwsConfig) Setup() {
+	flag.StringVar(&c.userAgent, "nws-user-agent", "wego https://github.com/schachmat/wego", "nws backend: the `USERAGENT` identifying you to api.weather.gov, should contain contact information")
+	flag.BoolVar(&c.debug, "nws-debug", false, "nws backend: print raw requests and responses, same as -log-level debug")
+
+	c.client.Transport = iface.HTTPClient.Transport
+	// The points endpoint redirects to the canonical coordinates. Make sure the
+	// mandatory User-Agent is sent along to the redirect target.
+	c.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after %d redirects", len(via))
+		}
+		req.Header.Set("User-Agent", c.userAgent)
+		req.Header.Set("Accept", "application/geo+json")
+		return nil
+	}
+}
+
+// CheckConfig reports an error if the User-Agent is missing.
+func (c *nwsConfig) CheckConfig() error {
+	if len(c.userAgent) == 0 {
+		return fmt.Errorf("No weather.gov User-Agent specified.\nThe api.weather.gov service rejects requests without one.")
+	}
+	return nil
+}
+
+func (c *nwsConfig) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
+	var ret iface.Data
+	var points nwsPointsResponse
+	var resp nwsForecastResponse
+
+	if err := c.CheckConfig(); err != nil {
+		return ret, err
+	}
+	coords, err := iface.ResolveLatLon(ctx, location)
+	if err != nil {
+		return ret, fmt.Errorf("Could not find the location `%s`: %v\nTry a place name or `40.748,-73.985` for example to get a forecast for New York", location, err)
+	}
+	location = coords
+	s := strings.Split(location, ",")
+	lat, _ := strconv.ParseFloat(s[0], 64)
+	lon, _ := strconv.ParseFloat(s[1], 64)
+
+	if err := c.fetch(ctx, fmt.Sprintf(nwsPuri, lat, lon), &points); err != nil {
+		return ret, fmt.Errorf("Failed to look up the weather.gov gridpoint: %v", err)
+	}
+	if points.Properties.ForecastHourly == "" {
+		return ret, fmt.Errorf("The weather.gov gridpoint for %s has no hourly forecast. Only US locations are supported.", location)
+	}
+
+	// the config is shared by concurrent requests, so the timezone of the
+	// location is passed to the parse functions
+	var tz *time.Location
+	if points.Properties.TimeZone != "" {
+		loc, err := time.LoadLocation(points.Properties.TimeZone)
+		if err != nil {
+			log.Printf("Unknown Timezone used in response (%s)", points.Properties.TimeZone)
+		} else {
+			tz = loc
+		}
+	}
+
+	if err := c.fetch(ctx, points.Properties.ForecastHourly, &resp); err != nil {
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+
+	ret.Attribution = "Data from the U.S. National Weather Service"
+	ret.Source = "nws"
+	ret.Location = location
+	if rel := points.Properties.RelativeLocation.Properties; rel.City != "" {
+		ret.Location = fmt.Sprintf("%s, %s", rel.City, rel.State)
+	}
+	ret.GeoLoc = &iface.LatLon{Latitude: float32(lat), Longitude: float32(lon)}
+
+	periods := resp.Properties.Periods
+	if len(periods) == 0 {
+		return ret, fmt.Errorf("The weather.gov response did not contain any weather data")
+	}
+
+	if ret.Current, err = c.parseCond(periods[0], tz); err != nil {
+		return ret, fmt.Errorf("Could not parse current weather condition: %v", err)
+	}
+
+	if numdays >= 1 {
+		ret.Forecast = c.parseDaily(periods, numdays, tz)
+	}
+	return ret, nil
+}
+
+func init() {
+	iface.AllBackends["nws"] = &nwsConfig{}
+}
@@ -0,0 +1,44 @@
+package backends
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNwsParseDaily(t *testing.T) {
+	local := time.Local
+	defer func() { time.Local = local }()
+	time.Local = time.FixedZone("UTC+10", 10*60*60)
+
+	c := &nwsConfig{}
+	var periods []nwsPeriod
+	// the same day of different months must not end up in the same day
+	for _, ts := range []string{"2020-05-31T18:00:00-05:00", "2020-05-31T23:00:00-05:00", "2020-06-01T00:00:00-05:00", "2020-07-01T06:00:00-05:00"} {
+		var p nwsPeriod
+		var err error
+		if p.StartTime, err = time.Parse(time.RFC3339, ts); err != nil {
+			t.Fatal(err)
+		}
+		periods = append(periods, p)
+	}
+
+	// without the timezone of the location the offsets of the response are
+	// used instead of the local timezone
+	for _, tc := range []struct {
+		tz   *time.Location
+		want []string
+	}{
+		{nil, []string{"2020-05-31 18:00 -0500", "2020-06-01 00:00 -0500", "2020-07-01 06:00 -0500"}},
+		{time.FixedZone("CDT", -5*60*60), []string{"2020-05-31 18:00 CDT", "2020-06-01 00:00 CDT", "2020-07-01 06:00 CDT"}},
+	} {
+		days := c.parseDaily(periods, 7, tc.tz)
+		if len(days) != len(tc.want) {
+			t.Fatalf("got %d days, want %d", len(days), len(tc.want))
+		}
+		for i, want := range tc.want {
+			if got := days[i].Date.Format("2006-01-02 15:04 MST"); got != want {
+				t.Errorf("day %d: got %s, want %s", i, got, want)
+			}
+		}
+	}
+}
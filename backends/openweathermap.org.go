@@ -1,13 +1,13 @@
 package backends
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/schachmat/wego/iface"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"regexp"
 	"strings"
 	"time"
@@ -55,17 +55,21 @@ const (
 	openweatherURI = "http://api.openweathermap.org/data/2.5/forecast?%s&appid=%s&units=metric&lang=%s"
 )
 
+// MaxDays is the five days covered by the free three hour forecast.
+func (c *openWeatherConfig) MaxDays() int {
+	return 5
+}
+
 func (c *openWeatherConfig) Setup() {
 	flag.StringVar(&c.apiKey, "owm-api-key", "", "openweathermap backend: the api `KEY` to use")
 	flag.StringVar(&c.lang, "owm-lang", "en", "openweathermap backend: the `LANGUAGE` to request from openweathermap")
-	flag.BoolVar(&c.debug, "owm-debug", false, "openweathermap backend: print raw requests and responses")
+	flag.BoolVar(&c.debug, "owm-debug", false, "openweathermap backend: print raw requests and responses, same as -log-level debug")
 }
 
-func (c *openWeatherConfig) fetch(url string) (*openWeatherResponse, error) {
-	res, err := http.Get(url)
-	if c.debug {
-		fmt.Printf("Fetching %s\n", url)
-	}
+func (c *openWeatherConfig) fetch(ctx context.Context, url string) (*openWeatherResponse, error) {
+	res, err := iface.Get(ctx, url)
+	url = iface.RedactURL(url)
+	iface.Logf(iface.LogDebug, "Fetching %s", url)
 	if err != nil {
 		return nil, fmt.Errorf(" Unable to get (%s) %v", url, err)
 	}
@@ -75,9 +79,7 @@ func (c *openWeatherConfig) fetch(url string) (*openWeatherResponse, error) {
 		return nil, fmt.Errorf("Unable to read response body (%s): %v", url, err)
 	}
 
-	if c.debug {
-		fmt.Printf("Response (%s):\n%s\n", url, string(body))
-	}
+	iface.Logf(iface.LogDebug, "Response (%s):\n%s", url, string(body))
 
 	var resp openWeatherResponse
 	if err = json.Unmarshal(body, &resp); err != nil {
@@ -120,84 +122,90 @@ func (c *openWeatherConfig) parseDaily(dataInfo []dataBlock, numdays int) []ifac
 	return forecast
 }
 
+// openweatherCodemap maps the openweathermap condition ids to weather codes.
+var openweatherCodemap = map[int]iface.WeatherCode{
+	200: iface.CodeThunderyShowers,
+	201: iface.CodeThunderyShowers,
+	210: iface.CodeThunderyShowers,
+	230: iface.CodeThunderyShowers,
+	231: iface.CodeThunderyShowers,
+	202: iface.CodeThunderyHeavyRain,
+	211: iface.CodeThunderyHeavyRain,
+	212: iface.CodeThunderyHeavyRain,
+	221: iface.CodeThunderyHeavyRain,
+	232: iface.CodeThunderyHeavyRain,
+	300: iface.CodeLightRain,
+	301: iface.CodeLightRain,
+	310: iface.CodeLightRain,
+	311: iface.CodeLightRain,
+	313: iface.CodeLightRain,
+	321: iface.CodeLightRain,
+	302: iface.CodeHeavyRain,
+	312: iface.CodeHeavyRain,
+	314: iface.CodeHeavyRain,
+	500: iface.CodeLightShowers,
+	501: iface.CodeLightShowers,
+	502: iface.CodeHeavyShowers,
+	503: iface.CodeHeavyShowers,
+	504: iface.CodeHeavyShowers,
+	511: iface.CodeLightSleet,
+	520: iface.CodeLightShowers,
+	521: iface.CodeLightShowers,
+	522: iface.CodeHeavyShowers,
+	531: iface.CodeHeavyShowers,
+	600: iface.CodeLightSnow,
+	601: iface.CodeLightSnow,
+	602: iface.CodeHeavySnow,
+	611: iface.CodeLightSleet,
+	612: iface.CodeLightSleetShowers,
+	615: iface.CodeLightSleet,
+	616: iface.CodeLightSleet,
+	620: iface.CodeLightSnowShowers,
+	621: iface.CodeLightSnowShowers,
+	622: iface.CodeHeavySnowShowers,
+	701: iface.CodeFog,
+	711: iface.CodeFog,
+	721: iface.CodeFog,
+	741: iface.CodeFog,
+	731: iface.CodeUnknown, // sand, dust whirls
+	751: iface.CodeUnknown, // sand
+	761: iface.CodeUnknown, // dust
+	762: iface.CodeUnknown, // volcanic ash
+	771: iface.CodeUnknown, // squalls
+	781: iface.CodeUnknown, // tornado
+	800: iface.CodeSunny,
+	801: iface.CodePartlyCloudy,
+	802: iface.CodeCloudy,
+	803: iface.CodeVeryCloudy,
+	804: iface.CodeVeryCloudy,
+	900: iface.CodeUnknown, // tornado
+	901: iface.CodeUnknown, // tropical storm
+	902: iface.CodeUnknown, // hurricane
+	903: iface.CodeUnknown, // cold
+	904: iface.CodeUnknown, // hot
+	905: iface.CodeUnknown, // windy
+	906: iface.CodeUnknown, // hail
+	951: iface.CodeUnknown, // calm
+	952: iface.CodeUnknown, // light breeze
+	953: iface.CodeUnknown, // gentle breeze
+	954: iface.CodeUnknown, // moderate breeze
+	955: iface.CodeUnknown, // fresh breeze
+	956: iface.CodeUnknown, // strong breeze
+	957: iface.CodeUnknown, // high wind, near gale
+	958: iface.CodeUnknown, // gale
+	959: iface.CodeUnknown, // severe gale
+	960: iface.CodeUnknown, // storm
+	961: iface.CodeUnknown, // violent storm
+	962: iface.CodeUnknown, // hurricane
+}
+
+// CodeMap returns the openweathermap condition ids and the weather codes they map to.
+func (c *openWeatherConfig) CodeMap() map[string]iface.WeatherCode {
+	return iface.IntCodeMap(openweatherCodemap)
+}
+
 func (c *openWeatherConfig) parseCond(dataInfo dataBlock) (iface.Cond, error) {
 	var ret iface.Cond
-	codemap := map[int]iface.WeatherCode{
-		200: iface.CodeThunderyShowers,
-		201: iface.CodeThunderyShowers,
-		210: iface.CodeThunderyShowers,
-		230: iface.CodeThunderyShowers,
-		231: iface.CodeThunderyShowers,
-		202: iface.CodeThunderyHeavyRain,
-		211: iface.CodeThunderyHeavyRain,
-		212: iface.CodeThunderyHeavyRain,
-		221: iface.CodeThunderyHeavyRain,
-		232: iface.CodeThunderyHeavyRain,
-		300: iface.CodeLightRain,
-		301: iface.CodeLightRain,
-		310: iface.CodeLightRain,
-		311: iface.CodeLightRain,
-		313: iface.CodeLightRain,
-		321: iface.CodeLightRain,
-		302: iface.CodeHeavyRain,
-		312: iface.CodeHeavyRain,
-		314: iface.CodeHeavyRain,
-		500: iface.CodeLightShowers,
-		501: iface.CodeLightShowers,
-		502: iface.CodeHeavyShowers,
-		503: iface.CodeHeavyShowers,
-		504: iface.CodeHeavyShowers,
-		511: iface.CodeLightSleet,
-		520: iface.CodeLightShowers,
-		521: iface.CodeLightShowers,
-		522: iface.CodeHeavyShowers,
-		531: iface.CodeHeavyShowers,
-		600: iface.CodeLightSnow,
-		601: iface.CodeLightSnow,
-		602: iface.CodeHeavySnow,
-		611: iface.CodeLightSleet,
-		612: iface.CodeLightSleetShowers,
-		615: iface.CodeLightSleet,
-		616: iface.CodeLightSleet,
-		620: iface.CodeLightSnowShowers,
-		621: iface.CodeLightSnowShowers,
-		622: iface.CodeHeavySnowShowers,
-		701: iface.CodeFog,
-		711: iface.CodeFog,
-		721: iface.CodeFog,
-		741: iface.CodeFog,
-		731: iface.CodeUnknown, // sand, dust whirls
-		751: iface.CodeUnknown, // sand
-		761: iface.CodeUnknown, // dust
-		762: iface.CodeUnknown, // volcanic ash
-		771: iface.CodeUnknown, // squalls
-		781: iface.CodeUnknown, // tornado
-		800: iface.CodeSunny,
-		801: iface.CodePartlyCloudy,
-		802: iface.CodeCloudy,
-		803: iface.CodeVeryCloudy,
-		804: iface.CodeVeryCloudy,
-		900: iface.CodeUnknown, // tornado
-		901: iface.CodeUnknown, // tropical storm
-		902: iface.CodeUnknown, // hurricane
-		903: iface.CodeUnknown, // cold
-		904: iface.CodeUnknown, // hot
-		905: iface.CodeUnknown, // windy
-		906: iface.CodeUnknown, // hail
-		951: iface.CodeUnknown, // calm
-		952: iface.CodeUnknown, // light breeze
-		953: iface.CodeUnknown, // gentle breeze
-		954: iface.CodeUnknown, // moderate breeze
-		955: iface.CodeUnknown, // fresh breeze
-		956: iface.CodeUnknown, // strong breeze
-		957: iface.CodeUnknown, // high wind, near gale
-		958: iface.CodeUnknown, // gale
-		959: iface.CodeUnknown, // severe gale
-		960: iface.CodeUnknown, // storm
-		961: iface.CodeUnknown, // violent storm
-		962: iface.CodeUnknown, // hurricane
-	}
-
 	ret.Code = iface.CodeUnknown
 	ret.Desc = dataInfo.Weather[0].Description
 	ret.Humidity = &(dataInfo.Main.Humidity)
@@ -211,7 +219,7 @@ func (c *openWeatherConfig) parseCond(dataInfo dataBlock) (iface.Cond, error) {
 		windSpeed := (dataInfo.Wind.Speed * 3.6)
 		ret.WindspeedKmph = &(windSpeed)
 	}
-	if val, ok := codemap[dataInfo.Weather[0].ID]; ok {
+	if val, ok := openweatherCodemap[dataInfo.Weather[0].ID]; ok {
 		ret.Code = val
 	}
 
@@ -225,12 +233,20 @@ func (c *openWeatherConfig) parseCond(dataInfo dataBlock) (iface.Cond, error) {
 	return ret, nil
 }
 
-func (c *openWeatherConfig) Fetch(location string, numdays int) iface.Data {
+// CheckConfig reports an error if the api key is missing.
+func (c *openWeatherConfig) CheckConfig() error {
+	if len(c.apiKey) == 0 {
+		return fmt.Errorf("No openweathermap.org API key specified.\nYou have to register for one at https://home.openweathermap.org/users/sign_up")
+	}
+	return nil
+}
+
+func (c *openWeatherConfig) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
 	var ret iface.Data
 	loc := ""
 
-	if len(c.apiKey) == 0 {
-		log.Fatal("No openweathermap.org API key specified.\nYou have to register for one at https://home.openweathermap.org/users/sign_up")
+	if err := c.CheckConfig(); err != nil {
+		return ret, err
 	}
 	if matched, err := regexp.MatchString(`^-?[0-9]*(\.[0-9]+)?,-?[0-9]*(\.[0-9]+)?$`, location); matched && err == nil {
 		s := strings.Split(location, ",")
@@ -241,18 +257,23 @@ func (c *openWeatherConfig) Fetch(location string, numdays int) iface.Data {
 		loc = "q=" + location
 	}
 
-	resp, err := c.fetch(fmt.Sprintf(openweatherURI, loc, c.apiKey, c.lang))
+	resp, err := c.fetch(ctx, fmt.Sprintf(openweatherURI, loc, c.apiKey, c.lang))
 	if err != nil {
-		log.Fatalf("Failed to fetch weather data: %v\n", err)
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+	if len(resp.List) == 0 {
+		return ret, fmt.Errorf("Failed to fetch weather data: empty forecast list")
 	}
 	ret.Current, err = c.parseCond(resp.List[0])
+	ret.Attribution = "Weather data provided by OpenWeather"
+	ret.Source = "openweathermap"
 	ret.Location = fmt.Sprintf("%s, %s", resp.City.Name, resp.City.Country)
 
 	if err != nil {
-		log.Fatalf("Failed to fetch weather data: %v\n", err)
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
 	}
 	ret.Forecast = c.parseDaily(resp.List, numdays)
-	return ret
+	return ret, nil
 }
 
 func init() {
@@ -0,0 +1,53 @@
+package backends
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// pirateConfig reuses the forecast.io backend, because Pirate Weather serves
+// the exact same JSON schema as forecast.io and Dark Sky did.
+type pirateConfig struct {
+	forecastConfig
+}
+
+const (
+	// see https://docs.pirateweather.net/
+	pirateHost = "https://api.pirateweather.net"
+)
+
+func (c *pirateConfig) Setup() {
+	flag.StringVar(&c.apiKey, "pirate-api-key", "", "pirateweather backend: the api `KEY` to use")
+	flag.StringVar(&c.lang, "pirate-lang", "en", "pirateweather backend: the `LANGUAGE` to request from pirateweather")
+	flag.StringVar(&c.units, "pirate-units", "ca", "pirateweather backend: the `UNITS` to request from pirateweather (ca, us, si, uk2 or auto)")
+	flag.StringVar(&c.location, "pirate-default-location", "", "pirateweather backend: the latitude,longitude `LOCATION` to use with this backend if none is given on the command line, overrides -location")
+	flag.StringVar(&c.host, "pirate-host", pirateHost, "pirateweather backend: the `URL` of the pirateweather api server")
+	flag.StringVar(&c.userAgent, "pirate-user-agent", "", "pirateweather backend: the `USERAGENT` to send to pirateweather instead of wego/VERSION")
+	flag.BoolVar(&c.debug, "pirate-debug", false, "pirateweather backend: print raw requests and responses, same as -log-level debug")
+}
+
+// CheckConfig reports an error if the api key is missing.
+func (c *pirateConfig) CheckConfig() error {
+	if len(c.apiKey) == 0 {
+		return fmt.Errorf("No pirateweather API key specified.\nYou have to register for one at https://pirateweather.net/")
+	}
+	return nil
+}
+
+func (c *pirateConfig) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
+	if err := c.CheckConfig(); err != nil {
+		return iface.Data{}, err
+	}
+	ret, err := c.forecastConfig.Fetch(ctx, location, numdays)
+	ret.Attribution = "Powered by Pirate Weather"
+	ret.Source = "pirateweather"
+	return ret, err
+}
+
+func init() {
+	iface.AllBackends["pirateweather"] = &pirateConfig{forecastConfig{client: forecastClient, extend: true, timeout: 10 * time.Second}}
+}
@@ -0,0 +1,301 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type vcConfig struct {
+	apiKey  string
+	lang    string
+	history string
+	debug   bool
+}
+
+type vcCond struct {
+	DatetimeEpoch *int64   `json:"datetimeEpoch"`
+	Temp          *float32 `json:"temp"`
+	FeelsLike     *float32 `json:"feelslike"`
+	Humidity      *float32 `json:"humidity"`
+	Precip        *float32 `json:"precip"`
+	PrecipProb    *float32 `json:"precipprob"`
+	WindSpeed     *float32 `json:"windspeed"`
+	WindGust      *float32 `json:"windgust"`
+	WindDir       *float32 `json:"winddir"`
+	Visibility    *float32 `json:"visibility"`
+	Conditions    string   `json:"conditions"`
+	Icon          string   `json:"icon"`
+	SunriseEpoch  *int64   `json:"sunriseEpoch"`
+	SunsetEpoch   *int64   `json:"sunsetEpoch"`
+	MoonPhase     *float32 `json:"moonphase"`
+}
+
+type vcDay struct {
+	vcCond
+	Hours []vcCond `json:"hours"`
+}
+
+type vcResponse struct {
+	Latitude          *float32 `json:"latitude"`
+	Longitude         *float32 `json:"longitude"`
+	ResolvedAddress   string   `json:"resolvedAddress"`
+	Timezone          string   `json:"timezone"`
+	Days              []vcDay  `json:"days"`
+	CurrentConditions *vcCond  `json:"currentConditions"`
+}
+
+const (
+	// see https://www.visualcrossing.com/resources/documentation/weather-api/timeline-weather-api/
+	vcWuri = "https://weather.visualcrossing.com/VisualCrossingWebServices/rest/services/timeline/%s?key=%s&unitGroup=metric&include=hours,current&lang=%s&contentType=json"
+)
+
+// vcCodemap maps the visualcrossing icons to weather codes.
+var vcCodemap = map[string]iface.WeatherCode{
+	"snow":                  iface.CodeLightSnow,
+	"snow-showers-day":      iface.CodeLightSnowShowers,
+	"snow-showers-night":    iface.CodeLightSnow,
+	"thunder-rain":          iface.CodeThunderyHeavyRain,
+	"thunder-showers-day":   iface.CodeThunderyShowers,
+	"thunder-showers-night": iface.CodeThunderyShowers,
+	"rain":                  iface.CodeLightRain,
+	"showers-day":           iface.CodeLightShowers,
+	"showers-night":         iface.CodeLightRain,
+	"fog":                   iface.CodeFog,
+	"wind":                  iface.CodePartlyCloudy,
+	"cloudy":                iface.CodeCloudy,
+	"partly-cloudy-day":     iface.CodePartlyCloudy,
+	"partly-cloudy-night":   iface.CodePartlyCloudy,
+	"clear-day":             iface.CodeSunny,
+	"clear-night":           iface.CodeSunny,
+}
+
+// CodeMap returns the visualcrossing icons and the weather codes they map to.
+func (c *vcConfig) CodeMap() map[string]iface.WeatherCode {
+	return vcCodemap
+}
+
+func (c *vcConfig) parseCond(cond vcCond, tz *time.Location) (ret iface.Cond, err error) {
+	if cond.DatetimeEpoch == nil {
+		return iface.Cond{}, fmt.Errorf("The visualcrossing response did not provide a time for the weather condition")
+	}
+	ret.Time = time.Unix(*cond.DatetimeEpoch, 0).In(tz)
+
+	ret.Code = iface.CodeUnknown
+	if val, ok := vcCodemap[cond.Icon]; ok {
+		ret.Code = val
+	}
+
+	// the icon set does not distinguish intensities, so refine the code with
+	// the conditions text where possible
+	conditions := strings.ToLower(cond.Conditions)
+	switch {
+	case strings.Contains(conditions, "freezing") || strings.Contains(conditions, "ice"):
+		if ret.Code == iface.CodeLightRain || ret.Code == iface.CodeLightShowers {
+			ret.Code = iface.CodeLightSleet
+		}
+	case strings.Contains(conditions, "heavy"):
+		switch ret.Code {
+		case iface.CodeLightRain:
+			ret.Code = iface.CodeHeavyRain
+		case iface.CodeLightShowers:
+			ret.Code = iface.CodeHeavyShowers
+		case iface.CodeLightSnow:
+			ret.Code = iface.CodeHeavySnow
+		case iface.CodeLightSnowShowers:
+			ret.Code = iface.CodeHeavySnowShowers
+		}
+	case strings.Contains(conditions, "overcast"):
+		if ret.Code == iface.CodeCloudy {
+			ret.Code = iface.CodeVeryCloudy
+		}
+	}
+	ret.Desc = cond.Conditions
+
+	ret.TempC = cond.Temp
+	ret.FeelsLikeC = cond.FeelsLike
+
+	if cond.PrecipProb != nil && *cond.PrecipProb >= 0 && *cond.PrecipProb <= 100 {
+		p := int(math.Round(float64(*cond.PrecipProb)))
+		ret.ChanceOfRainPercent = &p
+	}
+
+	if cond.Precip != nil && *cond.Precip >= 0 {
+		p := *cond.Precip / 1000
+		ret.PrecipM = &p
+	}
+
+	if cond.Visibility != nil && *cond.Visibility >= 0 {
+		p := *cond.Visibility * 1000
+		ret.VisibleDistM = &p
+	}
+
+	if cond.WindSpeed != nil && *cond.WindSpeed >= 0 {
+		ret.WindspeedKmph = cond.WindSpeed
+	}
+
+	if cond.WindGust != nil && *cond.WindGust >= 0 {
+		ret.WindGustKmph = cond.WindGust
+	}
+
+	if cond.WindDir != nil && *cond.WindDir >= 0 {
+		p := int(math.Round(float64(*cond.WindDir))) % 360
+		ret.WinddirDegree = &p
+	}
+
+	if cond.Humidity != nil && *cond.Humidity >= 0 && *cond.Humidity <= 100 {
+		p := int(math.Round(float64(*cond.Humidity)))
+		ret.Humidity = &p
+	}
+
+	return ret, nil
+}
+
+func (c *vcConfig) parseDay(day vcDay, tz *time.Location) (ret iface.Day) {
+	if day.DatetimeEpoch != nil {
+		ret.Date = time.Unix(*day.DatetimeEpoch, 0).In(tz)
+	}
+	if day.SunriseEpoch != nil {
+		ret.Astronomy.Sunrise = time.Unix(*day.SunriseEpoch, 0).In(tz)
+	}
+	if day.SunsetEpoch != nil {
+		ret.Astronomy.Sunset = time.Unix(*day.SunsetEpoch, 0).In(tz)
+	}
+	if day.MoonPhase != nil && *day.MoonPhase >= 0 && *day.MoonPhase <= 1 {
+		ret.MoonPhase = day.MoonPhase
+	}
+
+	for _, hour := range day.Hours {
+		slot, err := c.parseCond(hour, tz)
+		if err != nil {
+			log.Println("Error parsing hourly weather condition:", err)
+			continue
+		}
+		ret.Slots = append(ret.Slots, slot)
+	}
+	return
+}
+
+func (c *vcConfig) fetch(ctx context.Context, url string) (*vcResponse, error) {
+	res, err := iface.Get(ctx, url)
+	url = iface.RedactURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get (%s): %v", url, err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read response body (%s): %v", url, err)
+	}
+
+	if res.StatusCode != 200 {
+		// errors are reported as plain text
+		return nil, fmt.Errorf("Unable to get (%s): http status %d: %s", url, res.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	iface.Logf(iface.LogDebug, "Response (%s): %s", url, string(body))
+
+	var resp vcResponse
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal response (%s): %v\nThe json body is: %s", url, err, string(body))
+	}
+	return &resp, nil
+}
+
+// MaxDays is the 15 days covered by the timeline api.
+func (c *vcConfig) MaxDays() int {
+	return 15
+}
+
+func (c *vcConfig) Setup() {
+	flag.StringVar(&c.apiKey, "vc-key", "", "visualcrossing backend: the api `KEY` to use")
+	flag.StringVar(&c.lang, "vc-lang", "en", "visualcrossing backend: the `LANGUAGE` to request from visualcrossing")
+	flag.StringVar(&c.history, "vc-history", "", "visualcrossing backend: fetch historical data for the `START/END` date range (e.g. 2020-01-01/2020-01-07) instead of a forecast")
+	flag.BoolVar(&c.debug, "vc-debug", false, "visualcrossing backend: print raw requests and responses, same as -log-level debug")
+}
+
+// CheckConfig reports an error if the api key is missing.
+func (c *vcConfig) CheckConfig() error {
+	if len(c.apiKey) == 0 {
+		return fmt.Errorf("No visualcrossing API key specified.\nYou have to register for one at https://www.visualcrossing.com/sign-up")
+	}
+	return nil
+}
+
+// Fetch gets the forecast for the next numdays days. If a history date range
+// is configured, the data for the requested past days is returned instead and
+// numdays is ignored.
+func (c *vcConfig) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
+	var ret iface.Data
+
+	if err := c.CheckConfig(); err != nil {
+		return ret, err
+	}
+
+	// without a date range the api returns the next 15 days starting today
+	// at the location, which is cut down to numdays below
+	path := url.PathEscape(location)
+	if c.history != "" {
+		if matched, err := regexp.MatchString(`^[0-9]{4}-[0-9]{2}-[0-9]{2}(/[0-9]{4}-[0-9]{2}-[0-9]{2})?$`, c.history); !matched || err != nil {
+			return ret, fmt.Errorf("The visualcrossing history must be a date range like `2020-01-01/2020-01-07`, not `%s`", c.history)
+		}
+		path += "/" + c.history
+	}
+
+	resp, err := c.fetch(ctx, fmt.Sprintf(vcWuri, path, c.apiKey, c.lang))
+	if err != nil {
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+
+	// the config is shared by concurrent requests, so the timezone of the
+	// location is passed to the parse functions
+	tz := time.Local
+	if resp.Timezone != "" {
+		if tz, err = time.LoadLocation(resp.Timezone); err != nil {
+			log.Printf("Unknown Timezone used in response (%s)", resp.Timezone)
+			tz = time.Local
+		}
+	}
+
+	ret.Attribution = "Weather data by Visual Crossing"
+	ret.Source = "visualcrossing"
+	ret.Location = location
+	if resp.ResolvedAddress != "" {
+		ret.Location = resp.ResolvedAddress
+	}
+	if resp.Latitude != nil && resp.Longitude != nil {
+		ret.GeoLoc = &iface.LatLon{Latitude: *resp.Latitude, Longitude: *resp.Longitude}
+	}
+
+	for _, day := range resp.Days {
+		if c.history == "" && len(ret.Forecast) >= numdays {
+			break
+		}
+		ret.Forecast = append(ret.Forecast, c.parseDay(day, tz))
+	}
+
+	if resp.CurrentConditions != nil {
+		if ret.Current, err = c.parseCond(*resp.CurrentConditions, tz); err != nil {
+			return ret, fmt.Errorf("Could not parse current weather condition: %v", err)
+		}
+	} else if len(ret.Forecast) > 0 && len(ret.Forecast[0].Slots) > 0 {
+		// historical queries do not come with current conditions
+		ret.Current = ret.Forecast[0].Slots[0]
+	}
+	return ret, nil
+}
+
+func init() {
+	iface.AllBackends["visualcrossing"] = &vcConfig{}
+}
@@ -0,0 +1,336 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type weatherapiConfig struct {
+	apiKey string
+	lang   string
+	debug  bool
+}
+
+type weatherapiCond struct {
+	TimeEpoch        *int64   `json:"time_epoch"`
+	LastUpdatedEpoch *int64   `json:"last_updated_epoch"`
+	TempC            *float32 `json:"temp_c"`
+	FeelsLikeC       *float32 `json:"feelslike_c"`
+	Condition        struct {
+		Text string `json:"text"`
+		Code int    `json:"code"`
+	} `json:"condition"`
+	WindKph      *float32 `json:"wind_kph"`
+	WindDegree   *int     `json:"wind_degree"`
+	GustKph      *float32 `json:"gust_kph"`
+	PrecipMM     *float32 `json:"precip_mm"`
+	Humidity     *int     `json:"humidity"`
+	VisKM        *float32 `json:"vis_km"`
+	ChanceOfRain *int     `json:"chance_of_rain"`
+	ChanceOfSnow *int     `json:"chance_of_snow"`
+	AirQuality   *struct {
+		PM25 *float32 `json:"pm2_5"`
+		PM10 *float32 `json:"pm10"`
+	} `json:"air_quality"`
+}
+
+type weatherapiDay struct {
+	Date  string `json:"date"`
+	Astro struct {
+		Sunrise  string `json:"sunrise"`
+		Sunset   string `json:"sunset"`
+		Moonrise string `json:"moonrise"`
+		Moonset  string `json:"moonset"`
+	} `json:"astro"`
+	Hour []weatherapiCond `json:"hour"`
+}
+
+type weatherapiResponse struct {
+	Location struct {
+		Name    string   `json:"name"`
+		Region  string   `json:"region"`
+		Country string   `json:"country"`
+		Lat     *float32 `json:"lat"`
+		Lon     *float32 `json:"lon"`
+		TzID    string   `json:"tz_id"`
+	} `json:"location"`
+	Current  weatherapiCond `json:"current"`
+	Forecast struct {
+		Forecastday []weatherapiDay `json:"forecastday"`
+	} `json:"forecast"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+const (
+	// see https://www.weatherapi.com/docs/
+	weatherapiWuri = "https://api.weatherapi.com/v1/forecast.json?key=%s&q=%s&days=%d&lang=%s&aqi=yes&alerts=no"
+)
+
+// weatherapiCodemap maps the weatherapi.com condition codes to weather codes.
+var weatherapiCodemap = map[int]iface.WeatherCode{
+	1000: iface.CodeSunny,
+	1003: iface.CodePartlyCloudy,
+	1006: iface.CodeCloudy,
+	1009: iface.CodeVeryCloudy,
+	1030: iface.CodeFog,
+	1063: iface.CodeLightShowers,
+	1066: iface.CodeLightSnowShowers,
+	1069: iface.CodeLightSleetShowers,
+	1072: iface.CodeLightSleet,
+	1087: iface.CodeThunderyShowers,
+	1114: iface.CodeLightSnow,
+	1117: iface.CodeHeavySnow,
+	1135: iface.CodeFog,
+	1147: iface.CodeFog,
+	1150: iface.CodeLightRain,
+	1153: iface.CodeLightRain,
+	1168: iface.CodeLightSleet,
+	1171: iface.CodeLightSleet,
+	1180: iface.CodeLightShowers,
+	1183: iface.CodeLightRain,
+	1186: iface.CodeLightShowers,
+	1189: iface.CodeLightRain,
+	1192: iface.CodeHeavyShowers,
+	1195: iface.CodeHeavyRain,
+	1198: iface.CodeLightSleet,
+	1201: iface.CodeLightSleet,
+	1204: iface.CodeLightSleet,
+	1207: iface.CodeLightSleet,
+	1210: iface.CodeLightSnowShowers,
+	1213: iface.CodeLightSnow,
+	1216: iface.CodeLightSnowShowers,
+	1219: iface.CodeLightSnow,
+	1222: iface.CodeHeavySnowShowers,
+	1225: iface.CodeHeavySnow,
+	1237: iface.CodeLightSleet,
+	1240: iface.CodeLightShowers,
+	1243: iface.CodeHeavyShowers,
+	1246: iface.CodeHeavyShowers,
+	1249: iface.CodeLightSleetShowers,
+	1252: iface.CodeLightSleetShowers,
+	1255: iface.CodeLightSnowShowers,
+	1258: iface.CodeHeavySnowShowers,
+	1261: iface.CodeLightSleetShowers,
+	1264: iface.CodeLightSleetShowers,
+	1273: iface.CodeThunderyShowers,
+	1276: iface.CodeThunderyHeavyRain,
+	1279: iface.CodeThunderySnowShowers,
+	1282: iface.CodeThunderySnowShowers,
+}
+
+// CodeMap returns the weatherapi.com condition codes and the weather codes they map to.
+func (c *weatherapiConfig) CodeMap() map[string]iface.WeatherCode {
+	return iface.IntCodeMap(weatherapiCodemap)
+}
+
+func (c *weatherapiConfig) parseCond(cond weatherapiCond, tz *time.Location) (ret iface.Cond, err error) {
+	if cond.TimeEpoch != nil {
+		ret.Time = time.Unix(*cond.TimeEpoch, 0).In(tz)
+	} else if cond.LastUpdatedEpoch != nil {
+		ret.Time = time.Unix(*cond.LastUpdatedEpoch, 0).In(tz)
+	} else {
+		return iface.Cond{}, fmt.Errorf("The weatherapi.com response did not provide a time for the weather condition")
+	}
+
+	ret.Code = iface.CodeUnknown
+	if val, ok := weatherapiCodemap[cond.Condition.Code]; ok {
+		ret.Code = val
+	}
+	ret.Desc = cond.Condition.Text
+
+	ret.TempC = cond.TempC
+	ret.FeelsLikeC = cond.FeelsLikeC
+
+	if cond.ChanceOfRain != nil {
+		p := *cond.ChanceOfRain
+		if cond.ChanceOfSnow != nil && *cond.ChanceOfSnow > p {
+			p = *cond.ChanceOfSnow
+		}
+		if p >= 0 && p <= 100 {
+			ret.ChanceOfRainPercent = &p
+		}
+	}
+
+	if cond.PrecipMM != nil && *cond.PrecipMM >= 0 {
+		p := *cond.PrecipMM / 1000
+		ret.PrecipM = &p
+	}
+
+	if cond.VisKM != nil && *cond.VisKM >= 0 {
+		p := *cond.VisKM * 1000
+		ret.VisibleDistM = &p
+	}
+
+	if aq := cond.AirQuality; aq != nil {
+		if aq.PM25 != nil && *aq.PM25 >= 0 {
+			ret.PM25 = aq.PM25
+		}
+		if aq.PM10 != nil && *aq.PM10 >= 0 {
+			ret.PM10 = aq.PM10
+		}
+		ret.AQI = iface.AQIFromPM(ret.PM25, ret.PM10)
+	}
+
+	if cond.WindKph != nil && *cond.WindKph >= 0 {
+		ret.WindspeedKmph = cond.WindKph
+	}
+
+	if cond.GustKph != nil && *cond.GustKph >= 0 {
+		ret.WindGustKmph = cond.GustKph
+	}
+
+	if cond.WindDegree != nil && *cond.WindDegree >= 0 {
+		p := *cond.WindDegree % 360
+		ret.WinddirDegree = &p
+	}
+
+	if cond.Humidity != nil && *cond.Humidity >= 0 && *cond.Humidity <= 100 {
+		ret.Humidity = cond.Humidity
+	}
+
+	return ret, nil
+}
+
+func (c *weatherapiConfig) parseDay(day weatherapiDay, tz *time.Location) (ret iface.Day) {
+	date, err := time.ParseInLocation("2006-01-02", day.Date, tz)
+	if err != nil {
+		log.Println("Error parsing forecast date:", err)
+	}
+	ret.Date = date
+
+	// astronomy times are given like "06:34 AM" and may be something like
+	// "No moonrise" if the event does not happen on that day
+	astro := func(s string) time.Time {
+		t, err := time.ParseInLocation("2006-01-02 03:04 PM", day.Date+" "+s, tz)
+		if err != nil {
+			return time.Time{}
+		}
+		return t
+	}
+	ret.Astronomy.Sunrise = astro(day.Astro.Sunrise)
+	ret.Astronomy.Sunset = astro(day.Astro.Sunset)
+	ret.Astronomy.Moonrise = astro(day.Astro.Moonrise)
+	ret.Astronomy.Moonset = astro(day.Astro.Moonset)
+
+	for _, hour := range day.Hour {
+		slot, err := c.parseCond(hour, tz)
+		if err != nil {
+			log.Println("Error parsing hourly weather condition:", err)
+			continue
+		}
+		ret.Slots = append(ret.Slots, slot)
+	}
+	return
+}
+
+func (c *weatherapiConfig) fetch(ctx context.Context, url string) (*weatherapiResponse, error) {
+	res, err := iface.Get(ctx, url)
+	url = iface.RedactURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get (%s): %v", url, err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read response body (%s): %v", url, err)
+	}
+
+	iface.Logf(iface.LogDebug, "Response (%s): %s", url, string(body))
+
+	var resp weatherapiResponse
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal response (%s): %v\nThe json body is: %s", url, err, string(body))
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("Erroneous response (%s): %s", url, resp.Error.Message)
+	} else if res.StatusCode != 200 {
+		return nil, fmt.Errorf("Unable to get (%s): http status %d", url, res.StatusCode)
+	}
+	return &resp, nil
+}
+
+// MaxDays is the 14 days allowed by the forecast api.
+func (c *weatherapiConfig) MaxDays() int {
+	return 14
+}
+
+func (c *weatherapiConfig) Setup() {
+	flag.StringVar(&c.apiKey, "weatherapi-key", "", "weatherapi backend: the api `KEY` to use")
+	flag.StringVar(&c.lang, "weatherapi-lang", "en", "weatherapi backend: the `LANGUAGE` to request from weatherapi.com")
+	flag.BoolVar(&c.debug, "weatherapi-debug", false, "weatherapi backend: print raw requests and responses, same as -log-level debug")
+}
+
+// CheckConfig reports an error if the api key is missing.
+func (c *weatherapiConfig) CheckConfig() error {
+	if len(c.apiKey) == 0 {
+		return fmt.Errorf("No weatherapi.com API key specified.\nYou have to register for one at https://www.weatherapi.com/signup.aspx")
+	}
+	return nil
+}
+
+// Fetch passes the location through to weatherapi.com unchanged, so city
+// names, zip codes, airport codes and latitude,longitude pairs all work.
+func (c *weatherapiConfig) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
+	var ret iface.Data
+
+	if err := c.CheckConfig(); err != nil {
+		return ret, err
+	}
+
+	days := numdays
+	if days < 1 {
+		days = 1
+	}
+	resp, err := c.fetch(ctx, fmt.Sprintf(weatherapiWuri, c.apiKey, url.QueryEscape(location), days, c.lang))
+	if err != nil {
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+
+	// the config is shared by concurrent requests, so the timezone of the
+	// location is passed to the parse functions
+	tz := time.Local
+	if resp.Location.TzID != "" {
+		if tz, err = time.LoadLocation(resp.Location.TzID); err != nil {
+			log.Printf("Unknown Timezone used in response (%s)", resp.Location.TzID)
+			tz = time.Local
+		}
+	}
+
+	ret.Attribution = "Powered by WeatherAPI.com"
+	ret.Source = "weatherapi"
+	ret.Location = location
+	if resp.Location.Name != "" {
+		ret.Location = fmt.Sprintf("%s, %s", resp.Location.Name, resp.Location.Country)
+	}
+	if resp.Location.Lat != nil && resp.Location.Lon != nil {
+		ret.GeoLoc = &iface.LatLon{Latitude: *resp.Location.Lat, Longitude: *resp.Location.Lon}
+	}
+
+	if ret.Current, err = c.parseCond(resp.Current, tz); err != nil {
+		return ret, fmt.Errorf("Could not parse current weather condition: %v", err)
+	}
+
+	for _, day := range resp.Forecast.Forecastday {
+		if len(ret.Forecast) >= numdays {
+			break
+		}
+		ret.Forecast = append(ret.Forecast, c.parseDay(day, tz))
+	}
+	return ret, nil
+}
+
+func init() {
+	iface.AllBackends["weatherapi"] = &weatherapiConfig{}
+}
@@ -0,0 +1,311 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type weatherbitConfig struct {
+	apiKey string
+	lang   string
+	debug  bool
+}
+
+// weatherbitFloat accepts both JSON numbers and numbers quoted as strings,
+// because the api is not consistent about the coordinate types.
+type weatherbitFloat float32
+
+func (f *weatherbitFloat) UnmarshalJSON(b []byte) error {
+	v, err := strconv.ParseFloat(strings.Trim(string(b), `"`), 32)
+	if err != nil {
+		return err
+	}
+	*f = weatherbitFloat(v)
+	return nil
+}
+
+type weatherbitCond struct {
+	Ts          *int64   `json:"ts"`
+	Temp        *float32 `json:"temp"`
+	AppTemp     *float32 `json:"app_temp"`
+	WindSpd     *float32 `json:"wind_spd"`
+	WindGustSpd *float32 `json:"wind_gust_spd"`
+	WindDir     *int     `json:"wind_dir"`
+	Precip      *float32 `json:"precip"`
+	Pop         *int     `json:"pop"`
+	Rh          *int     `json:"rh"`
+	Vis         *float32 `json:"vis"`
+	Weather     struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"weather"`
+}
+
+type weatherbitResponse struct {
+	CityName    string           `json:"city_name"`
+	CountryCode string           `json:"country_code"`
+	Lat         *weatherbitFloat `json:"lat"`
+	Lon         *weatherbitFloat `json:"lon"`
+	Timezone    string           `json:"timezone"`
+	Data        []weatherbitCond `json:"data"`
+	Error       string           `json:"error"`
+}
+
+const (
+	// see https://www.weatherbit.io/api/weather-forecast-120-hour
+	weatherbitWuri = "https://api.weatherbit.io/v2.0/forecast/hourly?%s&key=%s&hours=%d&lang=%s&units=M"
+)
+
+// weatherbitCodemap maps the weatherbit weather codes to weather codes.
+var weatherbitCodemap = map[int]iface.WeatherCode{
+	200: iface.CodeThunderyShowers,
+	201: iface.CodeThunderyShowers,
+	202: iface.CodeThunderyHeavyRain,
+	230: iface.CodeThunderyShowers,
+	231: iface.CodeThunderyShowers,
+	232: iface.CodeThunderyShowers,
+	233: iface.CodeThunderyHeavyRain,
+	300: iface.CodeLightRain,
+	301: iface.CodeLightRain,
+	302: iface.CodeHeavyRain,
+	500: iface.CodeLightRain,
+	501: iface.CodeLightRain,
+	502: iface.CodeHeavyRain,
+	511: iface.CodeLightSleet,
+	520: iface.CodeLightShowers,
+	521: iface.CodeLightShowers,
+	522: iface.CodeHeavyShowers,
+	600: iface.CodeLightSnow,
+	601: iface.CodeLightSnow,
+	602: iface.CodeHeavySnow,
+	610: iface.CodeLightSleet,
+	611: iface.CodeLightSleet,
+	612: iface.CodeLightSleet,
+	621: iface.CodeLightSnowShowers,
+	622: iface.CodeHeavySnowShowers,
+	623: iface.CodeLightSnowShowers,
+	700: iface.CodeFog,
+	711: iface.CodeFog,
+	721: iface.CodeFog,
+	731: iface.CodeFog,
+	741: iface.CodeFog,
+	751: iface.CodeFog,
+	800: iface.CodeSunny,
+	801: iface.CodePartlyCloudy,
+	802: iface.CodePartlyCloudy,
+	803: iface.CodeCloudy,
+	804: iface.CodeVeryCloudy,
+	900: iface.CodeUnknown, // unknown precipitation
+}
+
+// CodeMap returns the weatherbit weather codes and the weather codes they map to.
+func (c *weatherbitConfig) CodeMap() map[string]iface.WeatherCode {
+	return iface.IntCodeMap(weatherbitCodemap)
+}
+
+func (c *weatherbitConfig) parseCond(cond weatherbitCond, tz *time.Location) (ret iface.Cond, err error) {
+	if cond.Ts == nil {
+		return iface.Cond{}, fmt.Errorf("The weatherbit response did not provide a time for the weather condition")
+	}
+	ret.Time = time.Unix(*cond.Ts, 0).In(tz)
+
+	ret.Code = iface.CodeUnknown
+	if val, ok := weatherbitCodemap[cond.Weather.Code]; ok {
+		ret.Code = val
+	}
+	ret.Desc = cond.Weather.Description
+
+	ret.TempC = cond.Temp
+	ret.FeelsLikeC = cond.AppTemp
+
+	if cond.Pop != nil && *cond.Pop >= 0 && *cond.Pop <= 100 {
+		ret.ChanceOfRainPercent = cond.Pop
+	}
+
+	if cond.Precip != nil && *cond.Precip >= 0 {
+		p := *cond.Precip / 1000
+		ret.PrecipM = &p
+	}
+
+	if cond.Vis != nil && *cond.Vis >= 0 {
+		p := *cond.Vis * 1000
+		ret.VisibleDistM = &p
+	}
+
+	if cond.WindSpd != nil && *cond.WindSpd >= 0 {
+		p := *cond.WindSpd * 3.6
+		ret.WindspeedKmph = &p
+	}
+
+	if cond.WindGustSpd != nil && *cond.WindGustSpd >= 0 {
+		p := *cond.WindGustSpd * 3.6
+		ret.WindGustKmph = &p
+	}
+
+	if cond.WindDir != nil && *cond.WindDir >= 0 {
+		p := *cond.WindDir % 360
+		ret.WinddirDegree = &p
+	}
+
+	if cond.Rh != nil && *cond.Rh >= 0 && *cond.Rh <= 100 {
+		ret.Humidity = cond.Rh
+	}
+
+	return ret, nil
+}
+
+func (c *weatherbitConfig) parseDaily(data []weatherbitCond, numdays int, tz *time.Location) []iface.Day {
+	var forecast []iface.Day
+	var day *iface.Day
+
+	for _, hour := range data {
+		slot, err := c.parseCond(hour, tz)
+		if err != nil {
+			log.Println("Error parsing hourly weather condition:", err)
+			continue
+		}
+
+		if day != nil && !forecastSameDate(day.Date, slot.Time) {
+			forecast = append(forecast, *day)
+			if len(forecast) >= numdays {
+				return forecast
+			}
+			day = nil
+		}
+		if day == nil {
+			day = new(iface.Day)
+			day.Date = slot.Time
+		}
+
+		day.Slots = append(day.Slots, slot)
+	}
+
+	if day != nil && len(forecast) < numdays {
+		forecast = append(forecast, *day)
+	}
+	return forecast
+}
+
+func (c *weatherbitConfig) fetch(ctx context.Context, url string) (*weatherbitResponse, error) {
+	res, err := iface.Get(ctx, url)
+	url = iface.RedactURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get (%s): %v", url, err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read response body (%s): %v", url, err)
+	}
+
+	iface.Logf(iface.LogDebug, "Response (%s): %s", url, string(body))
+
+	var resp weatherbitResponse
+	if res.StatusCode == http.StatusNoContent {
+		return nil, fmt.Errorf("No weatherbit data available for (%s)", url)
+	} else if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal response (%s): %v\nThe json body is: %s", url, err, string(body))
+	} else if resp.Error != "" {
+		return nil, fmt.Errorf("Erroneous response (%s): %s", url, resp.Error)
+	} else if res.StatusCode != 200 {
+		return nil, fmt.Errorf("Unable to get (%s): http status %d", url, res.StatusCode)
+	}
+	return &resp, nil
+}
+
+// MaxDays is ten days, as the hourly forecast is limited to 240 hours.
+func (c *weatherbitConfig) MaxDays() int {
+	return 10
+}
+
+func (c *weatherbitConfig) Setup() {
+	flag.StringVar(&c.apiKey, "weatherbit-key", "", "weatherbit backend: the api `KEY` to use")
+	flag.StringVar(&c.lang, "weatherbit-lang", "en", "weatherbit backend: the `LANGUAGE` to request from weatherbit")
+	flag.BoolVar(&c.debug, "weatherbit-debug", false, "weatherbit backend: print raw requests and responses, same as -log-level debug")
+}
+
+// CheckConfig reports an error if the api key is missing.
+func (c *weatherbitConfig) CheckConfig() error {
+	if len(c.apiKey) == 0 {
+		return fmt.Errorf("No weatherbit API key specified.\nYou have to register for one at https://www.weatherbit.io/account/create")
+	}
+	return nil
+}
+
+func (c *weatherbitConfig) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
+	var ret iface.Data
+	loc := ""
+
+	if err := c.CheckConfig(); err != nil {
+		return ret, err
+	}
+	if matched, err := regexp.MatchString(`^-?[0-9]*(\.[0-9]+)?,-?[0-9]*(\.[0-9]+)?$`, location); matched && err == nil {
+		s := strings.Split(location, ",")
+		loc = fmt.Sprintf("lat=%s&lon=%s", s[0], s[1])
+	} else {
+		loc = "city=" + url.QueryEscape(location)
+	}
+
+	// the hourly forecast is limited to 240 hours
+	hours := numdays * 24
+	if hours < 1 {
+		hours = 1
+	} else if hours > 240 {
+		hours = 240
+	}
+
+	resp, err := c.fetch(ctx, fmt.Sprintf(weatherbitWuri, loc, c.apiKey, hours, c.lang))
+	if err != nil {
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+
+	// the config is shared by concurrent requests, so the timezone of the
+	// location is passed to the parse functions. Without it the days are split
+	// in UTC, not at the local midnight of the machine running wego.
+	tz := time.UTC
+	if resp.Timezone != "" {
+		if tz, err = time.LoadLocation(resp.Timezone); err != nil {
+			log.Printf("Unknown Timezone used in response (%s)", resp.Timezone)
+			tz = time.UTC
+		}
+	}
+
+	ret.Attribution = "Weather data by Weatherbit.io"
+	ret.Source = "weatherbit"
+	ret.Location = location
+	if resp.CityName != "" {
+		ret.Location = fmt.Sprintf("%s, %s", resp.CityName, resp.CountryCode)
+	}
+	if resp.Lat != nil && resp.Lon != nil {
+		ret.GeoLoc = &iface.LatLon{Latitude: float32(*resp.Lat), Longitude: float32(*resp.Lon)}
+	}
+
+	if len(resp.Data) == 0 {
+		return ret, fmt.Errorf("The weatherbit response did not contain any weather data")
+	}
+	if ret.Current, err = c.parseCond(resp.Data[0], tz); err != nil {
+		return ret, fmt.Errorf("Could not parse current weather condition: %v", err)
+	}
+
+	if numdays >= 1 {
+		ret.Forecast = c.parseDaily(resp.Data, numdays, tz)
+	}
+	return ret, nil
+}
+
+func init() {
+	iface.AllBackends["weatherbit"] = &weatherbitConfig{}
+}
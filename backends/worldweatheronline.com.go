@@ -2,11 +2,12 @@ package backends
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -77,61 +78,68 @@ const (
 	wwoWuri = "https://api.worldweatheronline.com/free/v2/weather.ashx?"
 )
 
+// wwoCodemap maps the worldweatheronline weather codes to weather codes.
+var wwoCodemap = map[int]iface.WeatherCode{
+	113: iface.CodeSunny,
+	116: iface.CodePartlyCloudy,
+	119: iface.CodeCloudy,
+	122: iface.CodeVeryCloudy,
+	143: iface.CodeFog,
+	176: iface.CodeLightShowers,
+	179: iface.CodeLightSleetShowers,
+	182: iface.CodeLightSleet,
+	185: iface.CodeLightSleet,
+	200: iface.CodeThunderyShowers,
+	227: iface.CodeLightSnow,
+	230: iface.CodeHeavySnow,
+	248: iface.CodeFog,
+	260: iface.CodeFog,
+	263: iface.CodeLightShowers,
+	266: iface.CodeLightRain,
+	281: iface.CodeLightSleet,
+	284: iface.CodeLightSleet,
+	293: iface.CodeLightRain,
+	296: iface.CodeLightRain,
+	299: iface.CodeHeavyShowers,
+	302: iface.CodeHeavyRain,
+	305: iface.CodeHeavyShowers,
+	308: iface.CodeHeavyRain,
+	311: iface.CodeLightSleet,
+	314: iface.CodeLightSleet,
+	317: iface.CodeLightSleet,
+	320: iface.CodeLightSnow,
+	323: iface.CodeLightSnowShowers,
+	326: iface.CodeLightSnowShowers,
+	329: iface.CodeHeavySnow,
+	332: iface.CodeHeavySnow,
+	335: iface.CodeHeavySnowShowers,
+	338: iface.CodeHeavySnow,
+	350: iface.CodeLightSleet,
+	353: iface.CodeLightShowers,
+	356: iface.CodeHeavyShowers,
+	359: iface.CodeHeavyRain,
+	362: iface.CodeLightSleetShowers,
+	365: iface.CodeLightSleetShowers,
+	368: iface.CodeLightSnowShowers,
+	371: iface.CodeHeavySnowShowers,
+	374: iface.CodeLightSleetShowers,
+	377: iface.CodeLightSleet,
+	386: iface.CodeThunderyShowers,
+	389: iface.CodeThunderyHeavyRain,
+	392: iface.CodeThunderySnowShowers,
+	395: iface.CodeHeavySnowShowers,
+}
+
+// CodeMap returns the worldweatheronline weather codes and the weather codes they map to.
+func (c *wwoConfig) CodeMap() map[string]iface.WeatherCode {
+	return iface.IntCodeMap(wwoCodemap)
+}
+
 func wwoParseCond(cond wwoCond, date time.Time) (ret iface.Cond) {
 	ret.ChanceOfRainPercent = cond.TmpCor
 
-	codemap := map[int]iface.WeatherCode{
-		113: iface.CodeSunny,
-		116: iface.CodePartlyCloudy,
-		119: iface.CodeCloudy,
-		122: iface.CodeVeryCloudy,
-		143: iface.CodeFog,
-		176: iface.CodeLightShowers,
-		179: iface.CodeLightSleetShowers,
-		182: iface.CodeLightSleet,
-		185: iface.CodeLightSleet,
-		200: iface.CodeThunderyShowers,
-		227: iface.CodeLightSnow,
-		230: iface.CodeHeavySnow,
-		248: iface.CodeFog,
-		260: iface.CodeFog,
-		263: iface.CodeLightShowers,
-		266: iface.CodeLightRain,
-		281: iface.CodeLightSleet,
-		284: iface.CodeLightSleet,
-		293: iface.CodeLightRain,
-		296: iface.CodeLightRain,
-		299: iface.CodeHeavyShowers,
-		302: iface.CodeHeavyRain,
-		305: iface.CodeHeavyShowers,
-		308: iface.CodeHeavyRain,
-		311: iface.CodeLightSleet,
-		314: iface.CodeLightSleet,
-		317: iface.CodeLightSleet,
-		320: iface.CodeLightSnow,
-		323: iface.CodeLightSnowShowers,
-		326: iface.CodeLightSnowShowers,
-		329: iface.CodeHeavySnow,
-		332: iface.CodeHeavySnow,
-		335: iface.CodeHeavySnowShowers,
-		338: iface.CodeHeavySnow,
-		350: iface.CodeLightSleet,
-		353: iface.CodeLightShowers,
-		356: iface.CodeHeavyShowers,
-		359: iface.CodeHeavyRain,
-		362: iface.CodeLightSleetShowers,
-		365: iface.CodeLightSleetShowers,
-		368: iface.CodeLightSnowShowers,
-		371: iface.CodeHeavySnowShowers,
-		374: iface.CodeLightSleetShowers,
-		377: iface.CodeLightSleet,
-		386: iface.CodeThunderyShowers,
-		389: iface.CodeThunderyHeavyRain,
-		392: iface.CodeThunderySnowShowers,
-		395: iface.CodeHeavySnowShowers,
-	}
 	ret.Code = iface.CodeUnknown
-	if val, ok := codemap[cond.TmpCode]; ok {
+	if val, ok := wwoCodemap[cond.TmpCode]; ok {
 		ret.Code = val
 	}
 
@@ -247,16 +255,21 @@ func wwoUnmarshalLang(body []byte, r *wwoResponse, lang string) error {
 	return json.NewDecoder(&buf).Decode(r)
 }
 
+// MaxDays is the five days covered by the free api.
+func (c *wwoConfig) MaxDays() int {
+	return 5
+}
+
 func (c *wwoConfig) Setup() {
 	flag.StringVar(&c.apiKey, "wwo-api-key", "", "worldweatheronline backend: the api `KEY` to use")
 	flag.StringVar(&c.language, "wwo-lang", "en", "worldweatheronline backend: the `LANGUAGE` to request from worldweatheronline")
-	flag.BoolVar(&c.debug, "wwo-debug", false, "worldweatheronline backend: print raw requests and responses")
+	flag.BoolVar(&c.debug, "wwo-debug", false, "worldweatheronline backend: print raw requests and responses, same as -log-level debug")
 }
 
-func (c *wwoConfig) getCoordinatesFromAPI(queryParams []string, res chan *iface.LatLon) {
+func (c *wwoConfig) getCoordinatesFromAPI(ctx context.Context, queryParams []string, res chan *iface.LatLon) {
 	var coordResp wwoCoordinateResp
 	requri := wwoSuri + strings.Join(queryParams, "&")
-	hres, err := http.Get(requri)
+	hres, err := iface.Get(ctx, requri)
 	if err != nil {
 		log.Println("Unable to fetch geo location:", err)
 		res <- nil
@@ -275,10 +288,8 @@ func (c *wwoConfig) getCoordinatesFromAPI(queryParams []string, res chan *iface.
 		return
 	}
 
-	if c.debug {
-		log.Println("Geo location request:", requri)
-		log.Println("Geo location response:", string(body))
-	}
+	iface.Logf(iface.LogDebug, "Geo location request: %s", iface.RedactURL(requri))
+	iface.Logf(iface.LogDebug, "Geo location response: %s", string(body))
 
 	if err = json.Unmarshal(body, &coordResp); err != nil {
 		log.Println("Unable to unmarshal geo location data:", err)
@@ -296,14 +307,22 @@ func (c *wwoConfig) getCoordinatesFromAPI(queryParams []string, res chan *iface.
 	res <- &iface.LatLon{Latitude: *r[0].Latitude, Longitude: *r[0].Longitude}
 }
 
-func (c *wwoConfig) Fetch(loc string, numdays int) iface.Data {
+// CheckConfig reports an error if the api key is missing.
+func (c *wwoConfig) CheckConfig() error {
+	if len(c.apiKey) == 0 {
+		return fmt.Errorf("No API key specified. Setup instructions are in the README.")
+	}
+	return nil
+}
+
+func (c *wwoConfig) Fetch(ctx context.Context, loc string, numdays int) (iface.Data, error) {
 	var params []string
 	var resp wwoResponse
 	var ret iface.Data
-	coordChan := make(chan *iface.LatLon)
+	coordChan := make(chan *iface.LatLon, 1)
 
-	if len(c.apiKey) == 0 {
-		log.Fatal("No API key specified. Setup instructions are in the README.")
+	if err := c.CheckConfig(); err != nil {
+		return ret, err
 	}
 	params = append(params, "key="+c.apiKey)
 
@@ -314,30 +333,29 @@ func (c *wwoConfig) Fetch(loc string, numdays int) iface.Data {
 	params = append(params, "num_of_days="+strconv.Itoa(numdays))
 	params = append(params, "tp=3")
 
-	go c.getCoordinatesFromAPI(params, coordChan)
+	go c.getCoordinatesFromAPI(ctx, params, coordChan)
 
 	if c.language != "" {
 		params = append(params, "lang="+c.language)
 	}
 	requri := wwoWuri + strings.Join(params, "&")
 
-	res, err := http.Get(requri)
+	res, err := iface.Get(ctx, requri)
 	if err != nil {
-		log.Fatal("Unable to get weather data: ", err)
+		return ret, fmt.Errorf("Unable to get weather data: %v", err)
 	} else if res.StatusCode != 200 {
-		log.Fatal("Unable to get weather data: http status ", res.StatusCode)
+		res.Body.Close()
+		return ret, fmt.Errorf("Unable to get weather data: http status %d", res.StatusCode)
 	}
 	defer res.Body.Close()
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		log.Fatal(err)
+		return ret, err
 	}
 
-	if c.debug {
-		log.Println("Weather request:", requri)
-		log.Println("Weather response:", string(body))
-	}
+	iface.Logf(iface.LogDebug, "Weather request: %s", iface.RedactURL(requri))
+	iface.Logf(iface.LogDebug, "Weather response: %s", string(body))
 
 	if c.language == "" {
 		if err = json.Unmarshal(body, &resp); err != nil {
@@ -351,11 +369,13 @@ func (c *wwoConfig) Fetch(loc string, numdays int) iface.Data {
 
 	if resp.Data.Req == nil || len(resp.Data.Req) < 1 {
 		if resp.Data.Err != nil && len(resp.Data.Err) >= 1 {
-			log.Fatal(resp.Data.Err[0].Msg)
+			return ret, fmt.Errorf("%s", resp.Data.Err[0].Msg)
 		}
-		log.Fatal("Malformed response.")
+		return ret, fmt.Errorf("Malformed response.")
 	}
 
+	ret.Attribution = "Powered by World Weather Online"
+	ret.Source = "worldweatheronline"
 	ret.Location = resp.Data.Req[0].Type + ": " + resp.Data.Req[0].Query
 	ret.GeoLoc = <-coordChan
 
@@ -369,7 +389,7 @@ func (c *wwoConfig) Fetch(loc string, numdays int) iface.Data {
 		}
 	}
 
-	return ret
+	return ret, nil
 }
 
 func init() {
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// completionValues returns the possible values of the flags which only take a
+// fixed set of them, keyed by flag name.
+func completionValues() map[string][]string {
+	units := []string{"metric", "imperial", "si", "metric-ms"}
+	shells := []string{"bash", "zsh", "fish"}
+	return map[string][]string{
+		"backend":    backendNames(),
+		"b":          backendNames(),
+		"frontend":   frontendNames(),
+		"f":          frontendNames(),
+		"units":      units,
+		"u":          units,
+		"completion": shells,
+	}
+}
+
+// completionFlags returns the sorted names of all flags.
+func completionFlags() (ret []string) {
+	flag.VisitAll(func(f *flag.Flag) {
+		ret = append(ret, f.Name)
+	})
+	sort.Strings(ret)
+	return
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintln(w, "_wego() {")
+	fmt.Fprintln(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"")
+	fmt.Fprintln(w, "\tcase \"$prev\" in")
+	values := completionValues()
+	for _, name := range completionFlags() {
+		if vals, ok := values[name]; ok {
+			fmt.Fprintf(w, "\t-%s|--%s)\n\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n\t\treturn ;;\n", name, name, strings.Join(vals, " "))
+		}
+	}
+	fmt.Fprintln(w, "\tesac")
+	var flags []string
+	for _, name := range completionFlags() {
+		flags = append(flags, "-"+name)
+	}
+	fmt.Fprintf(w, "\tif [[ \"$cur\" == -* ]]; then\n\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n\tfi\n", strings.Join(flags, " "))
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _wego wego")
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintln(w, "#compdef wego")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "_wego() {")
+	fmt.Fprintln(w, "\tcase $words[CURRENT-1] in")
+	values := completionValues()
+	for _, name := range completionFlags() {
+		if vals, ok := values[name]; ok {
+			fmt.Fprintf(w, "\t-%s|--%s)\n\t\tcompadd -- %s\n\t\treturn ;;\n", name, name, strings.Join(vals, " "))
+		}
+	}
+	fmt.Fprintln(w, "\tesac")
+	var flags []string
+	for _, name := range completionFlags() {
+		flags = append(flags, "-"+name)
+	}
+	fmt.Fprintf(w, "\tif [[ $PREFIX == -* ]]; then\n\t\tcompadd -- %s\n\tfi\n", strings.Join(flags, " "))
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "compdef _wego wego")
+}
+
+func writeFishCompletion(w io.Writer) {
+	values := completionValues()
+	for _, name := range completionFlags() {
+		f := flag.Lookup(name)
+		_, usage := flag.UnquoteUsage(f)
+		usage = strings.SplitN(usage, "\n", 2)[0]
+		line := fmt.Sprintf("complete -c wego -o %s -d '%s'", name, strings.Replace(usage, "'", `\'`, -1))
+		if vals, ok := values[name]; ok {
+			line += fmt.Sprintf(" -x -a '%s'", strings.Join(vals, " "))
+		} else if !isBoolFlag(f) {
+			line += " -r"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// writeCompletion writes the completion script for shell to w.
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		writeZshCompletion(w)
+	case "fish":
+		writeFishCompletion(w)
+	default:
+		return fmt.Errorf("unknown shell `%s`, choices are: bash, zsh, fish", shell)
+	}
+	return nil
+}
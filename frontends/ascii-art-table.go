@@ -7,6 +7,7 @@ import (
 	"math"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,9 +19,175 @@ import (
 type aatConfig struct {
 	coords     bool
 	monochrome bool
+	slots      string
+	hours      []time.Duration
+	clock12    bool
+	sparkline  bool
+	astro      bool
+	visibility bool
+	iconset    string
+	beaufort   bool
+	arrows     bool
 	unit       iface.UnitSystem
 }
 
+const (
+	// the default times of day shown for each day
+	aatDefaultSlots = "8,12,19,23"
+
+	// slots further away from the requested time of day are not shown
+	aatMaxSlotDistance = 3 * time.Hour
+)
+
+// aatNerdfontCodes maps the weather codes to the weather glyphs of the nerd
+// fonts.
+var aatNerdfontCodes = map[iface.WeatherCode]string{
+	iface.CodeUnknown:             "\ue374",
+	iface.CodeCloudy:              "\ue33d",
+	iface.CodeFog:                 "\ue313",
+	iface.CodeHeavyRain:           "\ue318",
+	iface.CodeHeavyShowers:        "\ue319",
+	iface.CodeHeavySnow:           "\ue31a",
+	iface.CodeHeavySnowShowers:    "\ue31a",
+	iface.CodeLightRain:           "\ue31b",
+	iface.CodeLightShowers:        "\ue309",
+	iface.CodeLightSleet:          "\ue3ad",
+	iface.CodeLightSleetShowers:   "\ue3aa",
+	iface.CodeLightSnow:           "\ue31a",
+	iface.CodeLightSnowShowers:    "\ue30a",
+	iface.CodePartlyCloudy:        "\ue302",
+	iface.CodeSunny:               "\ue30d",
+	iface.CodeThunderyHeavyRain:   "\ue31d",
+	iface.CodeThunderyShowers:     "\ue31c",
+	iface.CodeThunderySnowShowers: "\ue365",
+	iface.CodeVeryCloudy:          "\ue312",
+	iface.CodeHail:                "\ue314",
+	iface.CodeFreezingRain:        "\ue316",
+	iface.CodeTornado:             "\ue351",
+}
+
+// aatGlyphIcon returns the icon for a single glyph from glyphs, centered in
+// the space of the ascii art icons. Codes missing from glyphs get a question
+// mark.
+func aatGlyphIcon(glyphs map[iface.WeatherCode]string, code iface.WeatherCode) []string {
+	glyph, ok := glyphs[code]
+	if !ok {
+		glyph = "?"
+	}
+	blank := strings.Repeat(" ", 13)
+	return []string{blank, blank, aatPad("      "+glyph, 13), blank, blank}
+}
+
+// aatParseSlots parses a comma separated list of hours of the day.
+func aatParseSlots(slots string) (ret []time.Duration, err error) {
+	for _, s := range strings.Split(slots, ",") {
+		h, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || h < 0 || h > 23 {
+			return nil, fmt.Errorf("invalid hour `%s`, must be in the range 0 to 23", s)
+		}
+		ret = append(ret, time.Duration(h)*time.Hour)
+	}
+	return ret, nil
+}
+
+// aatClockDistance returns the time between two times of day, wrapping around
+// midnight.
+func aatClockDistance(a, b time.Duration) time.Duration {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	if d > 12*time.Hour {
+		d = 24*time.Hour - d
+	}
+	return d
+}
+
+// colorDisabled reports whether colors must be left out, because the user
+// asked for it via NO_COLOR (see https://no-color.org/) or stdout is not a
+// terminal.
+func colorDisabled() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return true
+	}
+	fi, err := os.Stdout.Stat()
+	return err != nil || fi.Mode()&os.ModeCharDevice == 0
+}
+
+// windArrow returns the arrow pointing in the direction the wind blows to for
+// the direction in degrees it is blowing from.
+func windArrow(deg int) string {
+	arrows := []string{"↓", "↙", "←", "↖", "↑", "↗", "→", "↘"}
+	return arrows[((deg%360+360)%360+22)%360/45]
+}
+
+// windCompass returns the compass point the wind is blowing from for the
+// direction in degrees.
+func windCompass(deg int) string {
+	points := []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+	return points[((deg%360+360)%360+22)%360/45]
+}
+
+// sparkline plots the temperatures of the slots with block elements scaled to
+// the range of the given temperatures. Slots without temperature are left
+// blank, so the result has one rune per slot.
+func sparkline(slots []iface.Cond) string {
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	var lo, hi float32
+	found := false
+	for _, s := range slots {
+		if s.TempC == nil {
+			continue
+		}
+		if !found || *s.TempC < lo {
+			lo = *s.TempC
+		}
+		if !found || *s.TempC > hi {
+			hi = *s.TempC
+		}
+		found = true
+	}
+
+	ret := make([]rune, len(slots))
+	for i, s := range slots {
+		if s.TempC == nil {
+			ret[i] = ' '
+			continue
+		}
+		idx := 0
+		if hi > lo {
+			idx = int((*s.TempC - lo) / (hi - lo) * float32(len(blocks)-1))
+		}
+		ret[i] = blocks[idx]
+	}
+	return string(ret)
+}
+
+// nowcastSummary tells when precipitation starts or stops within the nowcast
+// slots, or returns the empty string if it does not change.
+func nowcastSummary(nowcast []iface.Cond, now time.Time) string {
+	wet := func(c iface.Cond) bool {
+		// at least 0.1 mm/h, smaller amounts are noise
+		return c.PrecipM != nil && *c.PrecipM >= 0.0001
+	}
+	if len(nowcast) == 0 {
+		return ""
+	}
+	for _, c := range nowcast[1:] {
+		if wet(c) != wet(nowcast[0]) {
+			mins := int(math.Ceil(c.Time.Sub(now).Minutes()))
+			if mins < 1 {
+				mins = 1
+			}
+			if wet(c) {
+				return fmt.Sprintf("Precipitation starting in %d min", mins)
+			}
+			return fmt.Sprintf("Precipitation ending in %d min", mins)
+		}
+	}
+	return ""
+}
+
 //TODO: replace s parameter with printf interface?
 func aatPad(s string, mustLen int) (ret string) {
 	ansiEsc := regexp.MustCompile("\033.*?m")
@@ -79,13 +246,16 @@ func (c *aatConfig) formatTemp(cond iface.Cond) string {
 	return aatPad(fmt.Sprintf("%s %s", color(t), u), 15)
 }
 
-func (c *aatConfig) formatWind(cond iface.Cond) string {
+// formatWind formats the wind speed and direction. In the current conditions
+// the Beaufort force is followed by its name.
+func (c *aatConfig) formatWind(cond iface.Cond, current bool) string {
 	windDir := func(deg *int) string {
 		if deg == nil {
 			return "?"
+		} else if !c.arrows {
+			return "\033[1m" + windCompass(*deg) + "\033[0m"
 		}
-		arrows := []string{"↓", "↙", "←", "↖", "↑", "↗", "→", "↘"}
-		return "\033[1m" + arrows[((*deg+22)%360)/45] + "\033[0m"
+		return "\033[1m" + windArrow(*deg) + "\033[0m"
 	}
 	color := func(spdKmph float32) string {
 		colmap := []struct {
@@ -104,31 +274,46 @@ func (c *aatConfig) formatWind(cond iface.Cond) string {
 			}
 		}
 
+		if c.beaufort {
+			return fmt.Sprintf("\033[38;5;%03dm%d\033[0m", col, *iface.Beaufort(&spdKmph))
+		}
 		s, _ := c.unit.Speed(spdKmph)
 		return fmt.Sprintf("\033[38;5;%03dm%d\033[0m", col, int(s))
 	}
 
 	_, u := c.unit.Speed(0.0)
+	if c.beaufort {
+		u = "Bft"
+	}
 
 	if cond.WindspeedKmph == nil {
 		return aatPad(windDir(cond.WinddirDegree), 15)
 	}
 	s := *cond.WindspeedKmph
+	width := 15
+	if c.beaufort && current {
+		// the current conditions are not in a table, so the name fits
+		u += " " + iface.BeaufortName(*iface.Beaufort(&s))
+		width = 30
+	}
 
 	if cond.WindGustKmph != nil {
 		if g := *cond.WindGustKmph; g > s {
-			return aatPad(fmt.Sprintf("%s %s – %s %s", windDir(cond.WinddirDegree), color(s), color(g), u), 15)
+			return aatPad(fmt.Sprintf("%s %s – %s %s", windDir(cond.WinddirDegree), color(s), color(g), u), width)
 		}
 	}
 
-	return aatPad(fmt.Sprintf("%s %s %s", windDir(cond.WinddirDegree), color(s), u), 15)
+	return aatPad(fmt.Sprintf("%s %s %s", windDir(cond.WinddirDegree), color(s), u), width)
 }
 
 func (c *aatConfig) formatVisibility(cond iface.Cond) string {
-	if cond.VisibleDistM == nil {
+	if !c.visibility || cond.VisibleDistM == nil {
 		return aatPad("", 15)
 	}
-	v, u := c.unit.Distance(*cond.VisibleDistM)
+	v, u := c.unit.Visibility(*cond.VisibleDistM)
+	if v < 10 {
+		return aatPad(fmt.Sprintf("%.1f %s", v, u), 15)
+	}
 	return aatPad(fmt.Sprintf("%d %s", int(v), u), 15)
 }
 
@@ -281,11 +466,40 @@ func (c *aatConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret
 			"\033[38;5;240;1m (___.__)__) \033[0m",
 			"             ",
 		},
+		iface.CodeHail: {
+			"\033[38;5;240;1m     .-.     \033[0m",
+			"\033[38;5;240;1m    (   ).   \033[0m",
+			"\033[38;5;240;1m   (___(__)  \033[0m",
+			"\033[38;5;255;1m    o ° o °  \033[0m",
+			"\033[38;5;255;1m   ° o ° o   \033[0m",
+		},
+		iface.CodeFreezingRain: {
+			"\033[38;5;250m     .-.     \033[0m",
+			"\033[38;5;250m    (   ).   \033[0m",
+			"\033[38;5;250m   (___(__)  \033[0m",
+			"\033[38;5;111m    ʻ ʻ ʻ ʻ  \033[0m",
+			"\033[38;5;153m  =========  \033[0m",
+		},
+		iface.CodeTornado: {
+			"\033[38;5;240;1m     .--.    \033[0m",
+			"\033[38;5;240;1m  .-(    ).  \033[0m",
+			"\033[38;5;240;1m (___.__)__) \033[0m",
+			"\033[38;5;250m    \\~~~~/   \033[0m",
+			"\033[38;5;250m      \\/     \033[0m",
+		},
 	}
 
-	icon, ok := codes[cond.Code]
-	if !ok {
-		log.Fatalln("aat-frontend: The following weather code has no icon:", cond.Code)
+	var icon []string
+	switch c.iconset {
+	case "emoji":
+		icon = aatGlyphIcon(emojiCodes, cond.Code)
+	case "nerdfont":
+		icon = aatGlyphIcon(aatNerdfontCodes, cond.Code)
+	default:
+		var ok bool
+		if icon, ok = codes[cond.Code]; !ok {
+			icon = codes[iface.CodeUnknown]
+		}
 	}
 
 	desc := cond.Desc
@@ -295,7 +509,7 @@ func (c *aatConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret
 
 	ret = append(ret, fmt.Sprintf("%v %v %v", cur[0], icon[0], desc))
 	ret = append(ret, fmt.Sprintf("%v %v %v", cur[1], icon[1], c.formatTemp(cond)))
-	ret = append(ret, fmt.Sprintf("%v %v %v", cur[2], icon[2], c.formatWind(cond)))
+	ret = append(ret, fmt.Sprintf("%v %v %v", cur[2], icon[2], c.formatWind(cond, current)))
 	ret = append(ret, fmt.Sprintf("%v %v %v", cur[3], icon[3], c.formatVisibility(cond)))
 	ret = append(ret, fmt.Sprintf("%v %v %v", cur[4], icon[4], c.formatRain(cond)))
 	return
@@ -320,37 +534,50 @@ func (c *aatConfig) formatGeo(coords *iface.LatLon) (ret string) {
 }
 
 func (c *aatConfig) printDay(day iface.Day) (ret []string) {
-	desiredTimesOfDay := []time.Duration{
-		8 * time.Hour,
-		12 * time.Hour,
-		19 * time.Hour,
-		23 * time.Hour,
-	}
 	ret = make([]string, 5)
 	for i := range ret {
 		ret[i] = "│"
 	}
 
-	// save our selected elements from day.Slots in this array
-	cols := make([]iface.Cond, len(desiredTimesOfDay))
+	timeOfDay := func(t time.Time) time.Duration {
+		h, m, _ := t.Clock()
+		return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+	}
+
+	// save our selected elements from day.Slots in this array, nil means
+	// there is no slot close to the desired time of day
+	cols := make([]*iface.Cond, len(c.hours))
 	// find hourly data which fits the desired times of day best
-	for _, candidate := range day.Slots {
-		cand := candidate.Time.UTC().Sub(candidate.Time.Truncate(24 * time.Hour))
-		for i, col := range cols {
-			cur := col.Time.Sub(col.Time.Truncate(24 * time.Hour))
-			if col.Time.IsZero() || math.Abs(float64(cand-desiredTimesOfDay[i])) < math.Abs(float64(cur-desiredTimesOfDay[i])) {
-				cols[i] = candidate
+	for i := range day.Slots {
+		cand := &day.Slots[i]
+		for j, want := range c.hours {
+			dist := aatClockDistance(timeOfDay(cand.Time), want)
+			if dist > aatMaxSlotDistance {
+				continue
+			}
+			if cols[j] == nil || dist < aatClockDistance(timeOfDay(cols[j].Time), want) {
+				cols[j] = cand
 			}
 		}
 	}
 
 	for _, s := range cols {
-		ret = c.formatCond(ret, s, false)
+		if s == nil {
+			for i := range ret {
+				ret[i] += strings.Repeat(" ", 30)
+			}
+		} else {
+			ret = c.formatCond(ret, *s, false)
+		}
 		for i := range ret {
 			ret[i] = ret[i] + "│"
 		}
 	}
 
+	if c.slots != aatDefaultSlots {
+		return c.printCustomHeader(day, ret)
+	}
+
 	dateFmt := "┤ " + day.Date.Format("Mon 02. Jan") + " ├"
 	ret = append([]string{
 		"                                                       ┌─────────────┐                                                       ",
@@ -362,17 +589,104 @@ func (c *aatConfig) printDay(day iface.Day) (ret []string) {
 		"└──────────────────────────────┴──────────────────────────────┴──────────────────────────────┴──────────────────────────────┘")
 }
 
+func (c *aatConfig) printSparkline(day iface.Day) string {
+	var lo, hi *float32
+	for _, s := range day.Slots {
+		if s.TempC == nil {
+			continue
+		}
+		if lo == nil || *s.TempC < *lo {
+			lo = s.TempC
+		}
+		if hi == nil || *s.TempC > *hi {
+			hi = s.TempC
+		}
+	}
+	if lo == nil {
+		return ""
+	}
+	l, u := c.unit.Temp(*lo)
+	h, _ := c.unit.Temp(*hi)
+	return fmt.Sprintf(" %s  %d – %d %s", sparkline(day.Slots), int(l), int(h), u)
+}
+
+// formatClock formats the time of day of t in its location, either like 15:04
+// or like 3pm and 7:42am with the 12-hour clock.
+func (c *aatConfig) formatClock(t time.Time) string {
+	if !c.clock12 {
+		return t.Format("15:04")
+	} else if t.Minute() == 0 {
+		return t.Format("3pm")
+	}
+	return t.Format("3:04pm")
+}
+
+// printAstro returns the line with the sunrise and sunset of the day in the
+// timezone of the backend. It is empty if the backend did not provide them.
+func (c *aatConfig) printAstro(day iface.Day) string {
+	var parts []string
+	if !day.Astronomy.Sunrise.IsZero() {
+		parts = append(parts, "🌅 "+c.formatClock(day.Astronomy.Sunrise))
+	}
+	if !day.Astronomy.Sunset.IsZero() {
+		parts = append(parts, "🌇 "+c.formatClock(day.Astronomy.Sunset))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " " + strings.Join(parts, "  ")
+}
+
+// printCustomHeader frames the columns of a day with a header showing the
+// date and hour of each column.
+func (c *aatConfig) printCustomHeader(day iface.Day, cols []string) []string {
+	var labels, lines []string
+	for _, h := range c.hours {
+		y, m, d := day.Date.Date()
+		at := time.Date(y, m, d, int(h.Hours()), 0, 0, 0, day.Date.Location())
+		label := day.Date.Format("Mon 02. Jan") + " " + c.formatClock(at)
+		pad := 30 - runewidth.StringWidth(label)
+		labels = append(labels, strings.Repeat(" ", pad/2)+label+strings.Repeat(" ", pad-pad/2))
+		lines = append(lines, strings.Repeat("─", 30))
+	}
+	ret := append([]string{
+		"┌" + strings.Join(lines, "┬") + "┐",
+		"│" + strings.Join(labels, "│") + "│",
+		"├" + strings.Join(lines, "┼") + "┤"},
+		cols...)
+	return append(ret, "└"+strings.Join(lines, "┴")+"┘")
+}
+
 func (c *aatConfig) Setup() {
 	flag.BoolVar(&c.coords, "aat-coords", false, "aat-frontend: Show geo coordinates")
 	flag.BoolVar(&c.monochrome, "aat-monochrome", false, "aat-frontend: Monochrome output")
+	flag.BoolVar(&c.clock12, "aat-12h", false, "aat-frontend: Use the 12-hour clock")
+	flag.BoolVar(&c.visibility, "aat-visibility", true, "aat-frontend: Show the visibility")
+	flag.BoolVar(&c.sparkline, "aat-sparkline", false, "aat-frontend: Plot the temperature of all slots of a day below its table")
+	flag.BoolVar(&c.astro, "aat-astro", false, "aat-frontend: Show the sunrise and sunset of a day below its table")
+	flag.BoolVar(&c.beaufort, "aat-beaufort", false, "aat-frontend: Show the wind speed as force on the Beaufort scale")
+	flag.BoolVar(&c.arrows, "aat-wind-arrows", true, "aat-frontend: Show the wind direction as arrow instead of compass point")
+	flag.BoolVar(&c.arrows, "wind-arrows", true, "aat-frontend: Show the wind direction as arrow instead of compass point, same as -aat-wind-arrows")
+	flag.StringVar(&c.iconset, "aat-iconset", "ascii", "aat-frontend: The `ICONSET` for the weather conditions: ascii, emoji or nerdfont")
+	flag.StringVar(&c.slots, "aat-slots", aatDefaultSlots, "aat-frontend: Comma separated `HOURS` of the day to show in the forecast")
 }
 
 func (c *aatConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
 	c.unit = unitSystem
 
+	var err error
+	if c.hours, err = aatParseSlots(c.slots); err != nil {
+		log.Fatalf("aat-frontend: Invalid slots `%s`: %v", c.slots, err)
+	}
+	switch c.iconset {
+	case "ascii", "emoji", "nerdfont":
+	default:
+		log.Fatalf("aat-frontend: Unknown iconset `%s`, choices are: ascii, emoji, nerdfont", c.iconset)
+	}
+
 	fmt.Printf("Weather for %s%s\n\n", r.Location, c.formatGeo(r.GeoLoc))
 	stdout := colorable.NewColorableStdout()
-	if c.monochrome {
+	if c.monochrome || colorDisabled() {
 		stdout = colorable.NewNonColorable(os.Stdout)
 	}
 
@@ -380,17 +694,32 @@ func (c *aatConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
 	for _, val := range out {
 		fmt.Fprintln(stdout, val)
 	}
-
-	if len(r.Forecast) == 0 {
-		return
-	}
-	if r.Forecast == nil {
-		log.Fatal("No detailed weather forecast available.")
+	if s := nowcastSummary(r.Nowcast, time.Now()); s != "" {
+		fmt.Fprintln(stdout, s)
 	}
+
 	for _, d := range r.Forecast {
 		for _, val := range c.printDay(d) {
 			fmt.Fprintln(stdout, val)
 		}
+		if c.sparkline {
+			if line := c.printSparkline(d); line != "" {
+				fmt.Fprintln(stdout, line)
+			}
+		}
+		if c.astro {
+			if line := c.printAstro(d); line != "" {
+				fmt.Fprintln(stdout, line)
+			}
+		}
+	}
+
+	if r.Attribution != "" && r.Source != "" {
+		fmt.Fprintf(stdout, "%s (via %s)\n", r.Attribution, r.Source)
+	} else if r.Attribution != "" {
+		fmt.Fprintln(stdout, r.Attribution)
+	} else if r.Source != "" {
+		fmt.Fprintf(stdout, "via %s\n", r.Source)
 	}
 }
 
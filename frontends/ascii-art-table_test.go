@@ -0,0 +1,125 @@
+package frontends
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestWindArrow(t *testing.T) {
+	tests := []struct {
+		deg     int
+		arrow   string
+		compass string
+	}{
+		{0, "↓", "N"},
+		{22, "↓", "N"},
+		{23, "↙", "NE"},
+		{45, "↙", "NE"},
+		{90, "←", "E"},
+		{135, "↖", "SE"},
+		{180, "↑", "S"},
+		{225, "↗", "SW"},
+		{270, "→", "W"},
+		{315, "↘", "NW"},
+		{337, "↘", "NW"},
+		{338, "↓", "N"},
+		{359, "↓", "N"},
+		{360, "↓", "N"},
+		{-90, "→", "W"},
+		{810, "←", "E"},
+	}
+	for _, tt := range tests {
+		if got := windArrow(tt.deg); got != tt.arrow {
+			t.Errorf("windArrow(%d) = %s, want %s", tt.deg, got, tt.arrow)
+		}
+		if got := windCompass(tt.deg); got != tt.compass {
+			t.Errorf("windCompass(%d) = %s, want %s", tt.deg, got, tt.compass)
+		}
+	}
+}
+
+func TestAatWindArrows(t *testing.T) {
+	speed, dir := float32(10), 270
+	cond := iface.Cond{WindspeedKmph: &speed, WinddirDegree: &dir}
+	for _, tt := range []struct {
+		arrows bool
+		want   string
+	}{
+		{true, "\033[1m→\033[0m"},
+		{false, "\033[1mW\033[0m"},
+	} {
+		c := &aatConfig{unit: iface.UnitsMetric, arrows: tt.arrows}
+		if got := c.formatWind(cond, false); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("arrows %t: got %q, want it to start with %q", tt.arrows, got, tt.want)
+		}
+	}
+
+	c := &aatConfig{unit: iface.UnitsMetric, arrows: true}
+	if got := c.formatWind(iface.Cond{WindspeedKmph: &speed}, false); !strings.HasPrefix(got, "? ") {
+		t.Errorf("got %q without a direction, want the placeholder", got)
+	}
+}
+
+// aatTestRender renders the test data with the slots at 8 and 19 o'clock,
+// which show their time in the header.
+func aatTestRender(t *testing.T, c *aatConfig) string {
+	c.slots = "8,19"
+	c.iconset = "ascii"
+	c.visibility = true
+	c.arrows = true
+	return captureStdout(t, func() { c.Render(testData(), iface.UnitsMetric) })
+}
+
+func TestAatClock(t *testing.T) {
+	got := aatTestRender(t, &aatConfig{})
+	checkGolden(t, "aat-24h.golden", got)
+	if !strings.Contains(got, "Mon 01. Jun 08:00") || !strings.Contains(got, "Mon 01. Jun 19:00") {
+		t.Error("the slots are not labeled with the 24-hour clock")
+	}
+
+	got = aatTestRender(t, &aatConfig{clock12: true})
+	checkGolden(t, "aat-12h.golden", got)
+	if !strings.Contains(got, "Mon 01. Jun 8am") || !strings.Contains(got, "Mon 01. Jun 7pm") {
+		t.Error("the slots are not labeled with the 12-hour clock")
+	}
+
+	tz := time.FixedZone("", -5*60*60)
+	for _, tt := range []struct {
+		t       time.Time
+		clock12 bool
+		want    string
+	}{
+		{time.Date(2020, 6, 1, 15, 0, 0, 0, tz), false, "15:00"},
+		{time.Date(2020, 6, 1, 15, 0, 0, 0, tz), true, "3pm"},
+		{time.Date(2020, 6, 1, 7, 42, 0, 0, tz), true, "7:42am"},
+		{time.Date(2020, 6, 1, 0, 5, 0, 0, tz), true, "12:05am"},
+	} {
+		c := &aatConfig{clock12: tt.clock12}
+		if got := c.formatClock(tt.t); got != tt.want {
+			t.Errorf("formatClock(%v) with 12h %t = %s, want %s", tt.t, tt.clock12, got, tt.want)
+		}
+	}
+}
+
+// TestAatAstro renders a day with astronomy data and one without.
+func TestAatAstro(t *testing.T) {
+	got := aatTestRender(t, &aatConfig{astro: true, clock12: true})
+	checkGolden(t, "aat-astro.golden", got)
+	if n := strings.Count(got, "🌅"); n != 1 {
+		t.Errorf("got %d sunrise rows, want one for the day with astronomy data", n)
+	}
+	if !strings.Contains(got, " 🌅 4:46am  🌇 9:27pm\n") {
+		t.Error("the sunrise and sunset are missing or not in the timezone of the data")
+	}
+
+	c := &aatConfig{}
+	if got := c.printAstro(testData().Forecast[0]); got != " 🌅 04:46  🌇 21:27" {
+		t.Errorf("got astro row %q with the 24-hour clock", got)
+	}
+	if got := c.printAstro(iface.Day{}); got != "" {
+		t.Errorf("got astro row %q without data, want none", got)
+	}
+}
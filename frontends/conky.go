@@ -0,0 +1,69 @@
+package frontends
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/template"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type conkyConfig struct {
+	template string
+}
+
+const conkyDefaultTemplate = `${color grey}{{.Location}}$color
+{{icon .Current.Code}} {{.Current.Desc}} {{temp .Current.TempC}} {{speed .Current.WindspeedKmph}}
+{{range .Forecast}}${color grey}{{.Date.Format "Mon"}}$color {{icon .Condition}} {{temp .MintempC}} – {{temp .MaxtempC}}
+{{end}}`
+
+// conkyFuncs returns the helper functions for the template. The values are
+// converted to unit, missing values are shown as a dash.
+func conkyFuncs(unit iface.UnitSystem) template.FuncMap {
+	return template.FuncMap{
+		"icon": func(code iface.WeatherCode) string {
+			return emojiCodes[code]
+		},
+		"temp": func(tempC *float32) string {
+			if tempC == nil {
+				return "-"
+			}
+			t, u := unit.Temp(*tempC)
+			return fmt.Sprintf("%d%s", int(t), u)
+		},
+		"speed": func(kmph *float32) string {
+			if kmph == nil {
+				return "-"
+			}
+			s, u := unit.Speed(*kmph)
+			return fmt.Sprintf("%d %s", int(s), u)
+		},
+		"distance": func(m *float32) string {
+			if m == nil {
+				return "-"
+			}
+			d, u := unit.Distance(*m)
+			return fmt.Sprintf("%.1f %s", d, u)
+		},
+	}
+}
+
+func (c *conkyConfig) Setup() {
+	flag.StringVar(&c.template, "conky-template", conkyDefaultTemplate, "conky-frontend: the go `TEMPLATE` executed on the weather data, may contain conky variables. The functions icon, temp, speed and distance format the values")
+}
+
+func (c *conkyConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	tmpl, err := template.New("conky").Funcs(conkyFuncs(unitSystem)).Parse(c.template)
+	if err != nil {
+		log.Fatalf("conky-frontend: Invalid template: %v", err)
+	}
+	if err = tmpl.Execute(os.Stdout, r); err != nil {
+		log.Fatalf("conky-frontend: Unable to apply template: %v", err)
+	}
+}
+
+func init() {
+	iface.AllFrontends["conky"] = &conkyConfig{}
+}
@@ -0,0 +1,17 @@
+package frontends
+
+import (
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestConkyRender(t *testing.T) {
+	c := &conkyConfig{template: conkyDefaultTemplate}
+	checkGolden(t, "conky.golden", captureStdout(t, func() { c.Render(testData(), iface.UnitsMetric) }))
+
+	c.template = `{{temp .Current.TempC}} {{speed .Current.WindspeedKmph}} {{distance .Current.PrecipM}} {{distance (index .Forecast 0).PrecipTotalM}}`
+	if got, want := captureStdout(t, func() { c.Render(testData(), iface.UnitsImperial) }), "65°F 8 mph - 0.8 in"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,111 @@
+package frontends
+
+import (
+	"encoding/csv"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type csvConfig struct {
+	delimiter string
+	unit      iface.UnitSystem
+}
+
+func (c *csvConfig) formatFloat(v *float32, conv func(float32) (float32, string)) string {
+	if v == nil {
+		return ""
+	}
+	res, _ := conv(*v)
+	return strconv.FormatFloat(float64(res), 'f', 1, 32)
+}
+
+func (c *csvConfig) formatInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func (c *csvConfig) formatCond(cond iface.Cond) []string {
+	return []string{
+		cond.Time.Format("2006-01-02 15:04"),
+		cond.Desc,
+		c.formatFloat(cond.TempC, c.unit.Temp),
+		c.formatFloat(cond.FeelsLikeC, c.unit.Temp),
+		c.formatFloat(cond.WindspeedKmph, c.unit.Speed),
+		c.formatInt(cond.WinddirDegree),
+		c.formatFloat(cond.PrecipM, c.unit.Distance),
+		c.formatInt(cond.ChanceOfRainPercent),
+	}
+}
+
+func (c *csvConfig) Setup() {
+	flag.StringVar(&c.delimiter, "csv-delimiter", ",", "csv-frontend: the `CHARACTER` separating the fields")
+}
+
+func (c *csvConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	c.write([]iface.Data{r}, unitSystem, false)
+}
+
+// RenderAll writes the data of several locations into one table, with the
+// location in the first column.
+func (c *csvConfig) RenderAll(rs []iface.Data, unitSystem iface.UnitSystem) {
+	c.write(rs, unitSystem, true)
+}
+
+func (c *csvConfig) write(rs []iface.Data, unitSystem iface.UnitSystem, location bool) {
+	c.unit = unitSystem
+
+	w := csv.NewWriter(os.Stdout)
+	delim, size := utf8.DecodeRuneInString(c.delimiter)
+	if size == 0 || size != len(c.delimiter) {
+		log.Fatalf("csv-frontend: The delimiter must be a single character, not `%s`", c.delimiter)
+	}
+	w.Comma = delim
+
+	row := func(loc string, fields []string) []string {
+		if location {
+			return append([]string{loc}, fields...)
+		}
+		return fields
+	}
+
+	_, tu := c.unit.Temp(0)
+	_, su := c.unit.Speed(0)
+	_, pu := c.unit.Distance(0.001)
+	w.Write(row("location", []string{
+		"time",
+		"condition",
+		"temperature (" + tu + ")",
+		"feels like (" + tu + ")",
+		"wind speed (" + su + ")",
+		"wind direction (°)",
+		"precipitation (" + pu + "/h)",
+		"chance of rain (%)",
+	}))
+
+	for _, r := range rs {
+		// the current condition comes first, so there is output without
+		// forecast
+		w.Write(row(r.Location, c.formatCond(r.Current)))
+		for _, d := range r.Forecast {
+			for _, slot := range d.Slots {
+				w.Write(row(r.Location, c.formatCond(slot)))
+			}
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func init() {
+	iface.AllFrontends["csv"] = &csvConfig{}
+}
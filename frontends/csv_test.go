@@ -0,0 +1,51 @@
+package frontends
+
+import (
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestCsvRender(t *testing.T) {
+	c := &csvConfig{delimiter: ","}
+	got := captureStdout(t, func() { c.Render(testData(), iface.UnitsMetric) })
+
+	rows, err := csv.NewReader(strings.NewReader(got)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 10 {
+		t.Fatalf("got %d rows, want the header, the current condition and 8 slots", len(rows))
+	}
+	for i, want := range [][]string{
+		{"time", "condition", "temperature (°C)", "feels like (°C)", "wind speed (km/h)", "wind direction (°)", "precipitation (mm/h)", "chance of rain (%)"},
+		// the precipitation of the current condition is unknown
+		{"2020-06-01 10:00", "Partly cloudy", "18.5", "17.5", "14.4", "250", "", "20"},
+		{"2020-06-01 08:00", "Clear", "14.0", "13.0", "8.0", "90", "0.0", "0"},
+		{"2020-06-01 12:00", "Partly cloudy", "19.0", "18.0", "12.0", "180", "0.5", "10"},
+	} {
+		if !reflect.DeepEqual(rows[i], want) {
+			t.Errorf("row %d: got %q, want %q", i, rows[i], want)
+		}
+	}
+
+	c = &csvConfig{delimiter: ";"}
+	got = captureStdout(t, func() { c.RenderAll([]iface.Data{testData(), testData()}, iface.UnitsImperial) })
+	r := csv.NewReader(strings.NewReader(got))
+	r.Comma = ';'
+	if rows, err = r.ReadAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 19 {
+		t.Fatalf("got %d rows for two locations, want 19", len(rows))
+	}
+	if rows[0][0] != "location" || rows[0][3] != "temperature (°F)" {
+		t.Errorf("got header %q, want the location column and imperial units", rows[0])
+	}
+	if want := []string{"Berlin", "2020-06-01 10:00", "Partly cloudy", "65.3"}; !reflect.DeepEqual(rows[1][:4], want) {
+		t.Errorf("got row %q, want it to start with %q", rows[1], want)
+	}
+}
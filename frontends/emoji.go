@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"os"
 	"time"
 
 	colorable "github.com/mattn/go-colorable"
@@ -15,6 +16,33 @@ type emojiConfig struct {
 	unit iface.UnitSystem
 }
 
+// emojiCodes maps the weather codes to emoji icons. It is shared by all
+// frontends showing emoji.
+var emojiCodes = map[iface.WeatherCode]string{
+	iface.CodeUnknown:             "✨",
+	iface.CodeCloudy:              "☁️",
+	iface.CodeFog:                 "🌫",
+	iface.CodeHeavyRain:           "🌧",
+	iface.CodeHeavyShowers:        "🌧",
+	iface.CodeHeavySnow:           "❄️",
+	iface.CodeHeavySnowShowers:    "❄️",
+	iface.CodeLightRain:           "🌦",
+	iface.CodeLightShowers:        "🌦",
+	iface.CodeLightSleet:          "🌧",
+	iface.CodeLightSleetShowers:   "🌧",
+	iface.CodeLightSnow:           "🌨",
+	iface.CodeLightSnowShowers:    "🌨",
+	iface.CodePartlyCloudy:        "⛅️",
+	iface.CodeSunny:               "☀️",
+	iface.CodeThunderyHeavyRain:   "🌩",
+	iface.CodeThunderyShowers:     "⛈",
+	iface.CodeThunderySnowShowers: "⛈",
+	iface.CodeVeryCloudy:          "☁️",
+	iface.CodeHail:                "🧊",
+	iface.CodeFreezingRain:        "🌧",
+	iface.CodeTornado:             "🌪",
+}
+
 func (c *emojiConfig) formatTemp(cond iface.Cond) string {
 	color := func(temp float32) string {
 		colmap := []struct {
@@ -53,29 +81,7 @@ func (c *emojiConfig) formatTemp(cond iface.Cond) string {
 }
 
 func (c *emojiConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret []string) {
-	codes := map[iface.WeatherCode]string{
-		iface.CodeUnknown:             "✨",
-		iface.CodeCloudy:              "☁️",
-		iface.CodeFog:                 "🌫",
-		iface.CodeHeavyRain:           "🌧",
-		iface.CodeHeavyShowers:        "🌧",
-		iface.CodeHeavySnow:           "❄️",
-		iface.CodeHeavySnowShowers:    "❄️",
-		iface.CodeLightRain:           "🌦",
-		iface.CodeLightShowers:        "🌦",
-		iface.CodeLightSleet:          "🌧",
-		iface.CodeLightSleetShowers:   "🌧",
-		iface.CodeLightSnow:           "🌨",
-		iface.CodeLightSnowShowers:    "🌨",
-		iface.CodePartlyCloudy:        "⛅️",
-		iface.CodeSunny:               "☀️",
-		iface.CodeThunderyHeavyRain:   "🌩",
-		iface.CodeThunderyShowers:     "⛈",
-		iface.CodeThunderySnowShowers: "⛈",
-		iface.CodeVeryCloudy:          "☁️",
-	}
-
-	icon, ok := codes[cond.Code]
+	icon, ok := emojiCodes[cond.Code]
 	if !ok {
 		log.Fatalln("emoji-frontend: The following weather code has no icon:", cond.Code)
 	}
@@ -145,23 +151,24 @@ func (c *emojiConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
 
 	fmt.Printf("Weather for %s\n\n", r.Location)
 	stdout := colorable.NewColorableStdout()
+	if colorDisabled() {
+		stdout = colorable.NewNonColorable(os.Stdout)
+	}
 
 	out := c.formatCond(make([]string, 5), r.Current, true)
 	for _, val := range out {
 		fmt.Fprintln(stdout, val)
 	}
 
-	if len(r.Forecast) == 0 {
-		return
-	}
-	if r.Forecast == nil {
-		log.Fatal("No detailed weather forecast available.")
-	}
 	for _, d := range r.Forecast {
 		for _, val := range c.printDay(d) {
 			fmt.Fprintln(stdout, val)
 		}
 	}
+
+	if r.Attribution != "" {
+		fmt.Fprintln(stdout, r.Attribution)
+	}
 }
 
 func init() {
@@ -0,0 +1,134 @@
+package frontends
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+var updateGolden = flag.Bool("update", false, "write the output of the frontends to the golden files in testdata")
+
+func testFloat(f float32) *float32 {
+	return &f
+}
+
+func testInt(i int) *int {
+	return &i
+}
+
+// testData returns the weather data the frontends are tested with. The first
+// day has astronomy data, the second one does not.
+func testData() iface.Data {
+	tz := time.FixedZone("CEST", 2*60*60)
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2020, 6, day, hour, min, 0, 0, tz)
+	}
+	slot := func(t time.Time, code iface.WeatherCode, desc string, temp, wind float32, dir, rain int) iface.Cond {
+		return iface.Cond{
+			Time:                t,
+			Code:                code,
+			Desc:                desc,
+			TempC:               testFloat(temp),
+			FeelsLikeC:          testFloat(temp - 1),
+			ChanceOfRainPercent: testInt(rain),
+			PrecipM:             testFloat(float32(rain) / 20000),
+			VisibleDistM:        testFloat(10000),
+			WindspeedKmph:       testFloat(wind),
+			WinddirDegree:       testInt(dir),
+			Humidity:            testInt(60),
+		}
+	}
+
+	r := iface.Data{
+		Current: iface.Cond{
+			Time:                at(1, 10, 0),
+			Code:                iface.CodePartlyCloudy,
+			Desc:                "Partly cloudy",
+			TempC:               testFloat(18.5),
+			FeelsLikeC:          testFloat(17.5),
+			ChanceOfRainPercent: testInt(20),
+			VisibleDistM:        testFloat(10000),
+			WindspeedKmph:       testFloat(14.4),
+			WinddirDegree:       testInt(250),
+			Humidity:            testInt(65),
+		},
+		Forecast: []iface.Day{
+			{
+				Date: at(1, 0, 0),
+				Slots: []iface.Cond{
+					slot(at(1, 8, 0), iface.CodeSunny, "Clear", 14, 8, 90, 0),
+					slot(at(1, 12, 0), iface.CodePartlyCloudy, "Partly cloudy", 19, 12, 180, 10),
+					slot(at(1, 19, 0), iface.CodeLightRain, "Light rain", 16, 20, 225, 60),
+					slot(at(1, 23, 0), iface.CodeCloudy, "Overcast", 12, 10, 270, 30),
+				},
+				Astronomy: iface.Astro{Sunrise: at(1, 4, 46), Sunset: at(1, 21, 27)},
+			},
+			{
+				Date: at(2, 0, 0),
+				Slots: []iface.Cond{
+					slot(at(2, 8, 0), iface.CodeHeavyRain, "Heavy rain", 13, 25, 0, 90),
+					slot(at(2, 12, 0), iface.CodeThunderyShowers, "Thunderstorm", 17, 35, 315, 80),
+					slot(at(2, 19, 0), iface.CodeLightRain, "Light rain", 15, 15, 270, 40),
+					slot(at(2, 23, 0), iface.CodeCloudy, "Overcast", 11, 5, 45, 20),
+				},
+			},
+		},
+		Location:    "Berlin",
+		GeoLoc:      &iface.LatLon{Latitude: 52.52, Longitude: 13.405},
+		Source:      "forecast.io",
+		Attribution: "Powered by Dark Sky",
+	}
+	for i := range r.Forecast {
+		iface.SummarizeDay(&r.Forecast[i])
+	}
+	return r
+}
+
+// captureStdout returns what f writes to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	rd, wr, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(rd)
+		out <- b
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = wr
+	defer func() { os.Stdout = stdout }()
+	f()
+	wr.Close()
+	return string(<-out)
+}
+
+// checkGolden compares got to the golden file testdata/name, or writes it to
+// the file with -update.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("the output differs from %s:\n%s\nwant:\n%s", path, got, want)
+	}
+}
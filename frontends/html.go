@@ -0,0 +1,157 @@
+package frontends
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type htmlConfig struct {
+	title string
+	unit  iface.UnitSystem
+}
+
+type htmlCond struct {
+	Time string
+	Icon string
+	Desc string
+	Temp string
+	Wind string
+	Rain string
+}
+
+type htmlDay struct {
+	Date  string
+	Slots []htmlCond
+}
+
+type htmlLocation struct {
+	Name        string
+	Current     htmlCond
+	Days        []htmlDay
+	Attribution string
+}
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; color: #222; }
+.current { font-size: 1.5em; margin-bottom: 1em; }
+.current .icon { font-size: 2em; vertical-align: middle; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+caption { font-weight: bold; text-align: left; padding: 0.3em 0; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+th { background: #eee; }
+footer { font-size: 0.8em; color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Locations}}{{if $.Multi}}<h2>{{.Name}}</h2>
+{{end}}<div class="current">
+<span class="icon">{{.Current.Icon}}</span> {{.Current.Desc}}<br>
+{{.Current.Temp}} · {{.Current.Wind}}{{if .Current.Rain}} · {{.Current.Rain}}{{end}}
+</div>
+{{range .Days}}<table>
+<caption>{{.Date}}</caption>
+<tr><th>Time</th><th></th><th>Condition</th><th>Temperature</th><th>Wind</th><th>Rain</th></tr>
+{{range .Slots}}<tr><td>{{.Time}}</td><td>{{.Icon}}</td><td>{{.Desc}}</td><td>{{.Temp}}</td><td>{{.Wind}}</td><td>{{.Rain}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Attribution}}<footer>{{.Attribution}}</footer>
+{{end}}{{end}}</body>
+</html>
+`
+
+func (c *htmlConfig) formatCond(cond iface.Cond) (ret htmlCond) {
+	ret.Time = cond.Time.Format("15:04")
+	ret.Icon = emojiCodes[cond.Code]
+	ret.Desc = cond.Desc
+
+	_, u := c.unit.Temp(0)
+	if cond.TempC != nil {
+		t, _ := c.unit.Temp(*cond.TempC)
+		ret.Temp = fmt.Sprintf("%d %s", int(t), u)
+		if cond.FeelsLikeC != nil {
+			fl, _ := c.unit.Temp(*cond.FeelsLikeC)
+			ret.Temp = fmt.Sprintf("%d (%d) %s", int(t), int(fl), u)
+		}
+	}
+
+	if cond.WindspeedKmph != nil {
+		s, u := c.unit.Speed(*cond.WindspeedKmph)
+		ret.Wind = fmt.Sprintf("%d %s", int(s), u)
+		if cond.WinddirDegree != nil {
+			ret.Wind = windArrow(*cond.WinddirDegree) + " " + ret.Wind
+		}
+	}
+
+	if cond.PrecipM != nil {
+		v, u := c.unit.Distance(*cond.PrecipM)
+		ret.Rain = fmt.Sprintf("%.1f %s/h", v, u)
+	}
+	if cond.ChanceOfRainPercent != nil {
+		if ret.Rain != "" {
+			ret.Rain += " | "
+		}
+		ret.Rain += fmt.Sprintf("%d%%", *cond.ChanceOfRainPercent)
+	}
+	return
+}
+
+func (c *htmlConfig) Setup() {
+	flag.StringVar(&c.title, "html-title", "", "html-frontend: the `TITLE` of the page, defaults to the location")
+}
+
+func (c *htmlConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	c.RenderAll([]iface.Data{r}, unitSystem)
+}
+
+// RenderAll writes a single page with a section for every location.
+func (c *htmlConfig) RenderAll(rs []iface.Data, unitSystem iface.UnitSystem) {
+	c.unit = unitSystem
+
+	data := struct {
+		Title     string
+		Multi     bool
+		Locations []htmlLocation
+	}{
+		Title: c.title,
+		Multi: len(rs) > 1,
+	}
+	if data.Title == "" && len(rs) == 1 {
+		data.Title = "Weather for " + rs[0].Location
+	} else if data.Title == "" {
+		data.Title = "Weather"
+	}
+	for _, r := range rs {
+		loc := htmlLocation{
+			Name:        r.Location,
+			Current:     c.formatCond(r.Current),
+			Attribution: r.Attribution,
+		}
+		for _, d := range r.Forecast {
+			day := htmlDay{Date: d.Date.Format("Mon Jan 2")}
+			for _, slot := range d.Slots {
+				day.Slots = append(day.Slots, c.formatCond(slot))
+			}
+			loc.Days = append(loc.Days, day)
+		}
+		data.Locations = append(data.Locations, loc)
+	}
+
+	t := template.Must(template.New("html").Parse(htmlTemplate))
+	if err := t.Execute(os.Stdout, data); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func init() {
+	iface.AllFrontends["html"] = &htmlConfig{}
+}
@@ -0,0 +1,97 @@
+package frontends
+
+import (
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// htmlCells parses the page and returns the text of the td cells of every
+// table row, as well as all captions.
+func htmlCells(t *testing.T, page string) (rows [][]string, captions []string) {
+	t.Helper()
+	d := xml.NewDecoder(strings.NewReader(page))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	var text *string
+	var row []string
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("the page does not parse: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "tr":
+				row = nil
+			case "td":
+				row = append(row, "")
+				text = &row[len(row)-1]
+			case "caption":
+				captions = append(captions, "")
+				text = &captions[len(captions)-1]
+			}
+		case xml.CharData:
+			if text != nil {
+				*text += string(tok)
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "tr":
+				if row != nil {
+					rows = append(rows, row)
+				}
+			case "td", "caption":
+				text = nil
+			}
+		}
+	}
+	return
+}
+
+func TestHtmlRender(t *testing.T) {
+	c := &htmlConfig{}
+	got := captureStdout(t, func() { c.Render(testData(), iface.UnitsMetric) })
+	if !strings.Contains(got, "<title>Weather for Berlin</title>") {
+		t.Error("the title does not default to the location")
+	}
+	if !strings.Contains(got, "<footer>Powered by Dark Sky</footer>") {
+		t.Error("the attribution is missing")
+	}
+
+	rows, captions := htmlCells(t, got)
+	if want := []string{"Mon Jun 1", "Tue Jun 2"}; !reflect.DeepEqual(captions, want) {
+		t.Errorf("got tables %q, want %q", captions, want)
+	}
+	if len(rows) != 8 {
+		t.Fatalf("got %d rows, want 8 slots", len(rows))
+	}
+	for i, want := range map[int][]string{
+		0: {"08:00", "☀️", "Clear", "14 (13) °C", "← 8 km/h", "0.0 mm/h | 0%"},
+		2: {"19:00", "🌦", "Light rain", "16 (15) °C", "↗ 20 km/h", "3.0 mm/h | 60%"},
+	} {
+		if !reflect.DeepEqual(rows[i], want) {
+			t.Errorf("row %d: got %q, want %q", i, rows[i], want)
+		}
+	}
+
+	// the names of the locations are escaped
+	r := testData()
+	r.Location = "<b>Home</b>"
+	got = captureStdout(t, func() { c.RenderAll([]iface.Data{r, testData()}, iface.UnitsMetric) })
+	if !strings.Contains(got, "<h2>&lt;b&gt;Home&lt;/b&gt;</h2>") || !strings.Contains(got, "<h2>Berlin</h2>") {
+		t.Error("the headings of the locations are missing or not escaped")
+	}
+	if rows, _ = htmlCells(t, got); len(rows) != 16 {
+		t.Errorf("got %d rows for two locations, want 16", len(rows))
+	}
+}
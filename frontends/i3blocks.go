@@ -0,0 +1,67 @@
+package frontends
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type i3blocksConfig struct {
+	format string
+	short  string
+}
+
+// i3blocksColor returns the color of the block for the weather code. Only
+// rain and worse weather is highlighted, the more severe the redder.
+func i3blocksColor(code iface.WeatherCode) string {
+	switch s := code.Severity(); {
+	case s >= iface.CodeThunderyShowers.Severity():
+		return "#FF0000"
+	case s >= iface.CodeHeavyShowers.Severity():
+		return "#FF8000"
+	case s >= iface.CodeLightShowers.Severity():
+		return "#FFFF00"
+	}
+	return ""
+}
+
+func (c *i3blocksConfig) Setup() {
+	flag.StringVar(&c.format, "i3blocks-format", "{{.Icon}} {{.Temp}} {{.Wind}}", "i3blocks-frontend: the go `TEMPLATE` for the full text, fields are Icon, Desc, Temp, FeelsLike, Wind, Rain, AQI and Location")
+	flag.StringVar(&c.short, "i3blocks-short", "{{.Icon}} {{.Temp}}", "i3blocks-frontend: the go `TEMPLATE` for the short text shown when the bar is crowded, same fields as -i3blocks-format")
+}
+
+func (c *i3blocksConfig) execute(name, format string, fields barFields) string {
+	tmpl, err := template.New(name).Parse(format)
+	if err != nil {
+		log.Fatalf("i3blocks-frontend: Invalid %s: %v", name, err)
+	}
+	var out bytes.Buffer
+	if err = tmpl.Execute(&out, fields); err != nil {
+		log.Fatalf("i3blocks-frontend: Unable to apply %s: %v", name, err)
+	}
+	// every line of the output has a meaning for i3blocks
+	return strings.Replace(out.String(), "\n", " ", -1)
+}
+
+// Render prints the three lines read by i3blocks: the full text, the short
+// text and the color, which is empty for the default color.
+func (c *i3blocksConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	fields := newBarFields(r.Current, r.Location, unitSystem)
+	fmt.Println(c.execute("format", c.format, fields))
+	fmt.Println(c.execute("short text", c.short, fields))
+	fmt.Println(i3blocksColor(r.Current.Code))
+}
+
+// MaxLocations is one, as i3blocks reads a single block.
+func (c *i3blocksConfig) MaxLocations() int {
+	return 1
+}
+
+func init() {
+	iface.AllFrontends["i3blocks"] = &i3blocksConfig{}
+}
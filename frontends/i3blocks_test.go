@@ -0,0 +1,37 @@
+package frontends
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestI3blocksRender(t *testing.T) {
+	c := &i3blocksConfig{format: "{{.Icon}} {{.Temp}}\n{{.Wind}}", short: "{{.Temp}}"}
+	got := captureStdout(t, func() { c.Render(testData(), iface.UnitsMetric) })
+	if want := "⛅️ 18°C → 14 km/h\n18°C\n\n"; got != want {
+		t.Errorf("got %q, want the full text on one line, the short text and no color", got)
+	}
+
+	r := testData()
+	r.Current.Code = iface.CodeThunderyShowers
+	got = captureStdout(t, func() { c.Render(r, iface.UnitsMetric) })
+	if lines := strings.Split(got, "\n"); len(lines) != 4 || lines[2] != "#FF0000" {
+		t.Errorf("got %q, want three lines with the color of a thunderstorm", got)
+	}
+}
+
+func TestI3blocksColor(t *testing.T) {
+	for code, want := range map[iface.WeatherCode]string{
+		iface.CodeSunny:           "",
+		iface.CodeCloudy:          "",
+		iface.CodeLightShowers:    "#FFFF00",
+		iface.CodeHeavyShowers:    "#FF8000",
+		iface.CodeThunderyShowers: "#FF0000",
+	} {
+		if got := i3blocksColor(code); got != want {
+			t.Errorf("%s: got color %q, want %q", code.Name(), got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package frontends
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type icalConfig struct {
+	unit iface.UnitSystem
+}
+
+// icalEscape escapes a TEXT value as described in RFC 5545 section 3.3.11.
+func icalEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// icalLine folds a content line after 75 octets without splitting utf-8
+// sequences and terminates it with CRLF.
+func icalLine(line string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range line {
+		l := len(string(r))
+		if n+l > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += l
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// dominantCond returns the first slot with the condition of day, so its
+// description can be used for the whole day.
+func dominantCond(day iface.Day) iface.Cond {
+	for _, slot := range day.Slots {
+		if slot.Code == day.Condition {
+			return slot
+		}
+	}
+	return iface.Cond{Code: day.Condition}
+}
+
+func (c *icalConfig) formatTemp(tempC *float32) string {
+	if tempC == nil {
+		return "?"
+	}
+	t, _ := c.unit.Temp(*tempC)
+	return fmt.Sprintf("%d", int(t))
+}
+
+func (c *icalConfig) formatDay(day iface.Day, location string, stamp time.Time) string {
+	var b strings.Builder
+	_, u := c.unit.Temp(0)
+	dom := dominantCond(day)
+
+	var desc []string
+	for _, slot := range day.Slots {
+		desc = append(desc, fmt.Sprintf("%s %s %s %s", slot.Time.Format("15:04"), emojiCodes[slot.Code], c.formatTemp(slot.TempC)+" "+u, slot.Desc))
+	}
+
+	date := day.Date.Format("20060102")
+	b.WriteString(icalLine("BEGIN:VEVENT"))
+	b.WriteString(icalLine(fmt.Sprintf("UID:wego-%s-%s", date, icalEscape(location))))
+	b.WriteString(icalLine("DTSTAMP:" + stamp.UTC().Format("20060102T150405Z")))
+	b.WriteString(icalLine("DTSTART;VALUE=DATE:" + date))
+	b.WriteString(icalLine("DTEND;VALUE=DATE:" + day.Date.AddDate(0, 0, 1).Format("20060102")))
+	b.WriteString(icalLine(fmt.Sprintf("SUMMARY:%s %s\\, %s – %s %s", emojiCodes[dom.Code], icalEscape(dom.Desc), c.formatTemp(day.MintempC), c.formatTemp(day.MaxtempC), u)))
+	b.WriteString(icalLine("DESCRIPTION:" + icalEscape(strings.Join(desc, "\n"))))
+	b.WriteString(icalLine("LOCATION:" + icalEscape(location)))
+	b.WriteString(icalLine("TRANSP:TRANSPARENT"))
+	b.WriteString(icalLine("END:VEVENT"))
+	return b.String()
+}
+
+func (c *icalConfig) Setup() {
+}
+
+func (c *icalConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	c.unit = unitSystem
+	now := time.Now()
+
+	fmt.Print(icalLine("BEGIN:VCALENDAR"))
+	fmt.Print(icalLine("VERSION:2.0"))
+	fmt.Print(icalLine("PRODID:-//schachmat//wego//EN"))
+	fmt.Print(icalLine("X-WR-CALNAME:" + icalEscape("Weather for "+r.Location)))
+	for _, d := range r.Forecast {
+		fmt.Print(c.formatDay(d, r.Location, now))
+	}
+	fmt.Print(icalLine("END:VCALENDAR"))
+}
+
+func init() {
+	iface.AllFrontends["ical"] = &icalConfig{}
+}
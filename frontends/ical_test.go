@@ -0,0 +1,111 @@
+package frontends
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// icalParse checks the content lines of a calendar and unfolds them. It
+// returns the properties of every VEVENT.
+func icalParse(t *testing.T, cal string) []map[string]string {
+	t.Helper()
+	if !strings.HasSuffix(cal, "\r\n") {
+		t.Fatal("the calendar does not end with CRLF")
+	}
+	var lines []string
+	for _, l := range strings.Split(strings.TrimSuffix(cal, "\r\n"), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line %q is longer than 75 octets", l)
+		}
+		if strings.Contains(l, "\n") {
+			t.Errorf("line %q is not terminated with CRLF", l)
+		}
+		if strings.HasPrefix(l, " ") && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+		} else {
+			lines = append(lines, l)
+		}
+	}
+
+	var stack []string
+	var events []map[string]string
+	props := map[string]string{}
+	for _, l := range lines {
+		i := strings.Index(l, ":")
+		if i < 1 {
+			t.Fatalf("line %q is not a content line", l)
+		}
+		name, value := l[:i], l[i+1:]
+		switch name {
+		case "BEGIN":
+			stack = append(stack, value)
+			props = map[string]string{}
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != value {
+				t.Fatalf("END:%s does not match the open components %q", value, stack)
+			}
+			stack = stack[:len(stack)-1]
+			if value == "VEVENT" {
+				events = append(events, props)
+			}
+			props = map[string]string{}
+		default:
+			props[name] = value
+		}
+		if name == "VERSION" && value != "2.0" {
+			t.Errorf("got version %q, want 2.0", value)
+		}
+	}
+	if len(stack) != 0 {
+		t.Errorf("the components %q are not closed", stack)
+	}
+	if lines[0] != "BEGIN:VCALENDAR" || !strings.Contains(cal, "\r\nPRODID:") {
+		t.Error("the calendar does not start with BEGIN:VCALENDAR and a PRODID")
+	}
+	return events
+}
+
+func TestIcalRender(t *testing.T) {
+	c := &icalConfig{}
+	r := testData()
+	r.Location = "Berlin, Mitte; Germany"
+	events := icalParse(t, captureStdout(t, func() { c.Render(r, iface.UnitsMetric) }))
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want one for every day", len(events))
+	}
+	for _, ev := range events {
+		for _, p := range []string{"UID", "DTSTAMP", "DTSTART;VALUE=DATE", "DTEND;VALUE=DATE", "SUMMARY"} {
+			if ev[p] == "" {
+				t.Errorf("the event %q has no %s", ev, p)
+			}
+		}
+	}
+
+	ev := events[1]
+	if ev["DTSTART;VALUE=DATE"] != "20200602" || ev["DTEND;VALUE=DATE"] != "20200603" {
+		t.Errorf("got the event from %s to %s, want the whole 2 June", ev["DTSTART;VALUE=DATE"], ev["DTEND;VALUE=DATE"])
+	}
+	if ev["LOCATION"] != `Berlin\, Mitte\; Germany` {
+		t.Errorf("got location %q, want it escaped", ev["LOCATION"])
+	}
+	if !strings.HasPrefix(ev["SUMMARY"], emojiCodes[r.Forecast[1].Condition]+" ") || !strings.HasSuffix(ev["SUMMARY"], "11 – 17 °C") {
+		t.Errorf("got summary %q, want the condition of the day and its temperature range", ev["SUMMARY"])
+	}
+	if !strings.Contains(ev["DESCRIPTION"], `\n12:00 ⛈ 17 °C Thunderstorm\n`) {
+		t.Errorf("got description %q, want a line for every slot", ev["DESCRIPTION"])
+	}
+}
+
+func TestIcalLine(t *testing.T) {
+	line := strings.Repeat("ä", 50)
+	got := icalLine(line)
+	parts := strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n ")
+	if len(parts) != 2 || len(parts[0]) != 74 {
+		t.Fatalf("got %q, want the line folded after 37 runes", got)
+	}
+	if strings.Join(parts, "") != line {
+		t.Errorf("got %q after unfolding, want %q", strings.Join(parts, ""), line)
+	}
+}
@@ -15,20 +15,30 @@ type jsnConfig struct {
 
 func (c *jsnConfig) Setup() {
 	flag.BoolVar(&c.noIndent, "jsn-no-indent", false, "json frontend: do not indent the output")
+	flag.BoolVar(&c.noIndent, "json-compact", false, "json frontend: print the output on a single line, same as -jsn-no-indent")
 }
 
-func (c *jsnConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+func (c *jsnConfig) write(v interface{}) {
 	var b []byte
 	var err error
 	if c.noIndent {
-		b, err = json.Marshal(r)
+		b, err = json.Marshal(v)
 	} else {
-		b, err = json.MarshalIndent(r, "", "\t")
+		b, err = json.MarshalIndent(v, "", "\t")
 	}
 	if err != nil {
 		log.Fatal(err)
 	}
-	os.Stdout.Write(b)
+	os.Stdout.Write(append(b, '\n'))
+}
+
+func (c *jsnConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	c.write(r)
+}
+
+// RenderAll writes the data of several locations as a json array.
+func (c *jsnConfig) RenderAll(rs []iface.Data, unitSystem iface.UnitSystem) {
+	c.write(rs)
 }
 
 func init() {
@@ -0,0 +1,32 @@
+package frontends
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestJsonRender(t *testing.T) {
+	r := testData()
+
+	c := &jsnConfig{}
+	got := captureStdout(t, func() { c.Render(r, iface.UnitsMetric) })
+	checkGolden(t, "json.golden", got)
+
+	c.noIndent = true
+	got = captureStdout(t, func() { c.Render(r, iface.UnitsMetric) })
+	if strings.Count(got, "\n") != 1 {
+		t.Errorf("got %d lines of compact output, want 1", strings.Count(got, "\n"))
+	}
+	checkGolden(t, "json-compact.golden", got)
+
+	var back iface.Data
+	if err := json.Unmarshal([]byte(got), &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Current.WindGustKmph != nil || !strings.Contains(got, `"WindGustKmph":null`) {
+		t.Error("unset fields are not written as null")
+	}
+}
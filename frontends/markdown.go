@@ -0,0 +1,74 @@
+package frontends
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type mdConfig struct {
+	emoji bool
+	unit  iface.UnitSystem
+}
+
+func (c *mdConfig) formatCond(cond iface.Cond) string {
+	desc := "-"
+	if cond.Desc != "" {
+		desc = strings.Replace(cond.Desc, "|", `\|`, -1)
+	}
+	if c.emoji {
+		desc = emojiCodes[cond.Code] + " " + desc
+	}
+
+	temp := "-"
+	if cond.TempC != nil {
+		t, u := c.unit.Temp(*cond.TempC)
+		temp = fmt.Sprintf("%d %s", int(t), u)
+	}
+
+	wind := "-"
+	if cond.WindspeedKmph != nil {
+		s, u := c.unit.Speed(*cond.WindspeedKmph)
+		wind = fmt.Sprintf("%d %s", int(s), u)
+		if cond.WinddirDegree != nil {
+			wind = windArrow(*cond.WinddirDegree) + " " + wind
+		}
+	}
+
+	return fmt.Sprintf("| %s | %s | %s | %s |", cond.Time.Format("15:04"), desc, temp, wind)
+}
+
+func (c *mdConfig) Setup() {
+	flag.BoolVar(&c.emoji, "markdown-emoji", false, "markdown-frontend: Show emoji icons for the weather conditions")
+}
+
+func (c *mdConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	c.unit = unitSystem
+	header := "| Time | Condition | Temperature | Wind |\n|------|-----------|-------------|------|"
+
+	fmt.Printf("## Weather for %s\n\n", r.Location)
+	fmt.Println(header)
+	fmt.Println(c.formatCond(r.Current))
+
+	for _, d := range r.Forecast {
+		fmt.Printf("\n### %s\n\n", d.Date.Format("Mon Jan 2"))
+		if d.PrecipTotalM != nil {
+			v, u := c.unit.Distance(*d.PrecipTotalM)
+			fmt.Printf("Expected precipitation: %.1f %s\n\n", v, u)
+		}
+		fmt.Println(header)
+		for _, slot := range d.Slots {
+			fmt.Println(c.formatCond(slot))
+		}
+	}
+
+	if r.Attribution != "" {
+		fmt.Printf("\n%s\n", r.Attribution)
+	}
+}
+
+func init() {
+	iface.AllFrontends["markdown"] = &mdConfig{}
+}
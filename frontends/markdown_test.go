@@ -0,0 +1,29 @@
+package frontends
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestMdRender(t *testing.T) {
+	for _, tc := range []struct {
+		golden string
+		emoji  bool
+	}{
+		{"markdown.golden", false},
+		{"markdown-emoji.golden", true},
+	} {
+		c := &mdConfig{emoji: tc.emoji}
+		checkGolden(t, tc.golden, captureStdout(t, func() { c.Render(testData(), iface.UnitsMetric) }))
+	}
+
+	// pipes in the description must not end the table cell
+	r := testData()
+	r.Current.Desc = "Rain | Snow"
+	c := &mdConfig{}
+	if got := captureStdout(t, func() { c.Render(r, iface.UnitsMetric) }); !strings.Contains(got, `| 10:00 | Rain \| Snow | 18 °C |`) {
+		t.Errorf("got output %q, want the pipe in the description escaped", got)
+	}
+}
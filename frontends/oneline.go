@@ -0,0 +1,44 @@
+package frontends
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type onelineConfig struct {
+	format string
+}
+
+func (c *onelineConfig) Setup() {
+	flag.StringVar(&c.format, "oneline-format", "{{.Icon}} {{.Temp}} {{.Wind}}", "oneline-frontend: the go `TEMPLATE` for the line, fields are Icon, Desc, Temp, FeelsLike, Wind, Rain, AQI and Location")
+}
+
+// Render prints only the current conditions on a single line without any
+// colors, for shell prompts and scripts.
+func (c *onelineConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	tmpl, err := template.New("oneline").Parse(c.format)
+	if err != nil {
+		log.Fatalf("oneline-frontend: Invalid format: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err = tmpl.Execute(&out, newBarFields(r.Current, r.Location, unitSystem)); err != nil {
+		log.Fatalf("oneline-frontend: Unable to apply format: %v", err)
+	}
+	fmt.Println(strings.TrimSpace(strings.Replace(out.String(), "\n", " ", -1)))
+}
+
+// MaxLocations is one, as the output is a single line.
+func (c *onelineConfig) MaxLocations() int {
+	return 1
+}
+
+func init() {
+	iface.AllFrontends["oneline"] = &onelineConfig{}
+}
@@ -0,0 +1,25 @@
+package frontends
+
+import (
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestOnelineRender(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{"{{.Icon}} {{.Temp}} {{.Wind}}", "⛅️ 18°C → 14 km/h\n"},
+		{"{{.Location}}: {{.Desc}}, {{.Rain}}", "Berlin: Partly cloudy, 20%\n"},
+		// unknown values leave no trailing spaces and the output stays on
+		// one line
+		{"{{.Temp}}\n{{.AQI}}", "18°C\n"},
+	} {
+		c := &onelineConfig{format: tc.format}
+		if got := captureStdout(t, func() { c.Render(testData(), iface.UnitsMetric) }); got != tc.want {
+			t.Errorf("format %q: got %q, want %q", tc.format, got, tc.want)
+		}
+	}
+}
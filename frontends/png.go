@@ -0,0 +1,242 @@
+package frontends
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"os"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type pngConfig struct {
+	out    string
+	width  int
+	height int
+	unit   iface.UnitSystem
+}
+
+const (
+	pngMargin     = 12
+	pngLineHeight = 16
+	pngBigGlyph   = 64
+	pngGlyph      = 32
+)
+
+var (
+	pngBackground = color.RGBA{0x1d, 0x25, 0x33, 0xff}
+	pngForeground = color.RGBA{0xee, 0xee, 0xee, 0xff}
+	pngDimmed     = color.RGBA{0x99, 0xa3, 0xb3, 0xff}
+	pngSun        = color.RGBA{0xff, 0xcc, 0x33, 0xff}
+	pngCloud      = color.RGBA{0xcc, 0xcc, 0xcc, 0xff}
+	pngDarkCloud  = color.RGBA{0x88, 0x88, 0x88, 0xff}
+	pngRain       = color.RGBA{0x44, 0x99, 0xff, 0xff}
+	pngSnow       = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+func pngFillRect(dst draw.Image, r image.Rectangle, col color.Color) {
+	draw.Draw(dst, r, &image.Uniform{col}, image.Point{}, draw.Src)
+}
+
+func pngFillCircle(dst draw.Image, cx, cy, r int, col color.Color) {
+	for y := -r; y <= r; y++ {
+		for x := -r; x <= r; x++ {
+			if x*x+y*y <= r*r {
+				dst.Set(cx+x, cy+y, col)
+			}
+		}
+	}
+}
+
+func pngCloudShape(dst draw.Image, x, y, s int, col color.Color) {
+	pngFillCircle(dst, x+s*35/100, y+s*50/100, s*18/100, col)
+	pngFillCircle(dst, x+s*58/100, y+s*42/100, s*24/100, col)
+	pngFillCircle(dst, x+s*22/100, y+s*62/100, s*12/100, col)
+	pngFillCircle(dst, x+s*80/100, y+s*62/100, s*12/100, col)
+	pngFillRect(dst, image.Rect(x+s*22/100, y+s*50/100, x+s*80/100, y+s*74/100), col)
+}
+
+// pngPrecip draws n streaks of rain if drops is true or flakes of snow
+// otherwise below a cloud.
+func pngPrecip(dst draw.Image, x, y, s, n int, drops bool) {
+	for i := 0; i < n; i++ {
+		px := x + s*(25+i*50/n)/100
+		if drops {
+			pngFillRect(dst, image.Rect(px, y+s*80/100, px+s/16+1, y+s*95/100), pngRain)
+		} else {
+			pngFillCircle(dst, px, y+s*88/100, s/16+1, pngSnow)
+		}
+	}
+}
+
+// pngBolt draws a lightning bolt below a cloud.
+func pngBolt(dst draw.Image, x, y, s int) {
+	for i := 0; i < s*25/100; i++ {
+		pngFillCircle(dst, x+s*55/100-i/2, y+s*70/100+i, s/32+1, pngSun)
+		pngFillCircle(dst, x+s*45/100-i/2, y+s*80/100+i, s/32+1, pngSun)
+	}
+}
+
+// pngDrawGlyph draws an icon for the weather code into the square of size s
+// with its upper left corner at x, y.
+func pngDrawGlyph(dst draw.Image, code iface.WeatherCode, x, y, s int) {
+	switch code {
+	case iface.CodeSunny:
+		pngFillCircle(dst, x+s/2, y+s/2, s*30/100, pngSun)
+	case iface.CodePartlyCloudy:
+		pngFillCircle(dst, x+s*35/100, y+s*35/100, s*25/100, pngSun)
+		pngCloudShape(dst, x, y+s/8, s, pngCloud)
+	case iface.CodeCloudy:
+		pngCloudShape(dst, x, y, s, pngCloud)
+	case iface.CodeVeryCloudy:
+		pngCloudShape(dst, x, y-s/8, s, pngDarkCloud)
+		pngCloudShape(dst, x, y+s/8, s, pngCloud)
+	case iface.CodeFog:
+		for i := 0; i < 4; i++ {
+			oy := y + s*(25+i*15)/100
+			pngFillRect(dst, image.Rect(x+s*(15+i%2*10)/100, oy, x+s*(85-i%2*10)/100, oy+s/16+1), pngCloud)
+		}
+	case iface.CodeLightRain, iface.CodeLightShowers:
+		pngCloudShape(dst, x, y, s, pngCloud)
+		pngPrecip(dst, x, y, s, 2, true)
+	case iface.CodeHeavyRain, iface.CodeHeavyShowers:
+		pngCloudShape(dst, x, y, s, pngDarkCloud)
+		pngPrecip(dst, x, y, s, 4, true)
+	case iface.CodeLightSnow, iface.CodeLightSnowShowers:
+		pngCloudShape(dst, x, y, s, pngCloud)
+		pngPrecip(dst, x, y, s, 2, false)
+	case iface.CodeHeavySnow, iface.CodeHeavySnowShowers:
+		pngCloudShape(dst, x, y, s, pngDarkCloud)
+		pngPrecip(dst, x, y, s, 4, false)
+	case iface.CodeLightSleet, iface.CodeLightSleetShowers, iface.CodeFreezingRain, iface.CodeHail:
+		pngCloudShape(dst, x, y, s, pngCloud)
+		pngPrecip(dst, x, y, s, 2, true)
+		pngPrecip(dst, x+s/8, y, s, 2, false)
+	case iface.CodeThunderyShowers, iface.CodeThunderyHeavyRain, iface.CodeThunderySnowShowers:
+		pngCloudShape(dst, x, y, s, pngDarkCloud)
+		pngBolt(dst, x, y, s)
+	case iface.CodeTornado:
+		for i := 0; i < 5; i++ {
+			w := s * (70 - i*12) / 100
+			oy := y + s*(15+i*15)/100
+			pngFillRect(dst, image.Rect(x+(s-w)/2+i*s/40, oy, x+(s+w)/2+i*s/40, oy+s/10), pngDarkCloud)
+		}
+	default:
+		pngCloudShape(dst, x, y, s, pngDarkCloud)
+	}
+}
+
+func pngDrawText(dst draw.Image, x, y int, text string, col color.Color) {
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  &image.Uniform{col},
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y+11),
+	}
+	d.DrawString(text)
+}
+
+func (c *pngConfig) formatTemp(tempC *float32) string {
+	if tempC == nil {
+		return "?"
+	}
+	t, _ := c.unit.Temp(*tempC)
+	return fmt.Sprintf("%d", int(t))
+}
+
+func (c *pngConfig) formatCurrent(cond iface.Cond) (ret []string) {
+	ret = append(ret, cond.Desc)
+	_, u := c.unit.Temp(0)
+	if cond.TempC != nil {
+		temp := c.formatTemp(cond.TempC) + " " + u
+		if cond.FeelsLikeC != nil {
+			temp += fmt.Sprintf(" (feels like %s %s)", c.formatTemp(cond.FeelsLikeC), u)
+		}
+		ret = append(ret, temp)
+	}
+	if cond.WindspeedKmph != nil {
+		s, su := c.unit.Speed(*cond.WindspeedKmph)
+		ret = append(ret, fmt.Sprintf("Wind %d %s", int(s), su))
+	}
+	if cond.ChanceOfRainPercent != nil {
+		ret = append(ret, fmt.Sprintf("Chance of rain %d%%", *cond.ChanceOfRainPercent))
+	}
+	return
+}
+
+func (c *pngConfig) Setup() {
+	flag.StringVar(&c.out, "png-out", "wego.png", "png-frontend: `FILE` to write the image to")
+	flag.IntVar(&c.width, "png-width", 480, "png-frontend: `WIDTH` of the image in pixels")
+	flag.IntVar(&c.height, "png-height", 0, "png-frontend: `HEIGHT` of the image in pixels, 0 fits the content")
+}
+
+func (c *pngConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	c.unit = unitSystem
+
+	current := c.formatCurrent(r.Current)
+	height := c.height
+	if height <= 0 {
+		height = pngMargin + pngLineHeight*2 + pngBigGlyph + pngMargin + len(r.Forecast)*(pngGlyph+pngMargin/2) + pngMargin
+		if r.Attribution != "" {
+			height += pngLineHeight
+		}
+	}
+	if c.width <= 0 {
+		log.Fatalf("png-frontend: Invalid width %d", c.width)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, c.width, height))
+	pngFillRect(img, img.Bounds(), pngBackground)
+
+	y := pngMargin
+	pngDrawText(img, pngMargin, y, "Weather for "+r.Location, pngForeground)
+	y += pngLineHeight * 2
+
+	pngDrawGlyph(img, r.Current.Code, pngMargin, y, pngBigGlyph)
+	for i, line := range current {
+		pngDrawText(img, pngMargin*2+pngBigGlyph, y+i*pngLineHeight, line, pngForeground)
+	}
+	y += pngBigGlyph + pngMargin
+
+	_, u := c.unit.Temp(0)
+	for _, d := range r.Forecast {
+		dom := dominantCond(d)
+		pngDrawGlyph(img, dom.Code, pngMargin, y, pngGlyph)
+		pngDrawText(img, pngMargin*2+pngGlyph, y, d.Date.Format("Mon Jan 2"), pngForeground)
+		pngDrawText(img, pngMargin*2+pngGlyph, y+pngLineHeight, fmt.Sprintf("%s, %s - %s %s", dom.Desc, c.formatTemp(d.MintempC), c.formatTemp(d.MaxtempC), u), pngDimmed)
+		y += pngGlyph + pngMargin/2
+	}
+
+	if r.Attribution != "" {
+		pngDrawText(img, pngMargin, y, r.Attribution, pngDimmed)
+	}
+
+	f, err := os.Create(c.out)
+	if err != nil {
+		log.Fatalf("png-frontend: Unable to create image: %v", err)
+	}
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		log.Fatalf("png-frontend: Unable to encode image: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		log.Fatalf("png-frontend: Unable to write image: %v", err)
+	}
+}
+
+// MaxLocations is one, as the image shows a single location.
+func (c *pngConfig) MaxLocations() int {
+	return 1
+}
+
+func init() {
+	iface.AllFrontends["png"] = &pngConfig{}
+}
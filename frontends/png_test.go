@@ -0,0 +1,52 @@
+package frontends
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func pngTestRender(t *testing.T, c *pngConfig, r iface.Data) image.Image {
+	t.Helper()
+	c.out = filepath.Join(t.TempDir(), "wego.png")
+	c.Render(r, iface.UnitsMetric)
+
+	f, err := os.Open(c.out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("the image does not decode: %v", err)
+	}
+	return img
+}
+
+func TestPngRender(t *testing.T) {
+	img := pngTestRender(t, &pngConfig{width: 480}, testData())
+
+	// the height fits the current condition, two days and the attribution
+	if want := image.Rect(0, 0, 480, 12+32+64+12+2*38+12+16); img.Bounds() != want {
+		t.Errorf("got an image of %v, want %v", img.Bounds(), want)
+	}
+	if got := color.RGBAModel.Convert(img.At(0, 0)); got != pngBackground {
+		t.Errorf("got color %v in the corner, want the background", got)
+	}
+	// the current condition is partly cloudy, so the sun shows in its glyph
+	x, y := pngMargin+pngBigGlyph*35/100, pngMargin+pngLineHeight*2+pngBigGlyph*20/100
+	if got := color.RGBAModel.Convert(img.At(x, y)); got != pngSun {
+		t.Errorf("got color %v in the glyph of the current condition, want the sun", got)
+	}
+
+	r := testData()
+	r.Attribution = ""
+	if img = pngTestRender(t, &pngConfig{width: 200, height: 300}, r); img.Bounds() != image.Rect(0, 0, 200, 300) {
+		t.Errorf("got an image of %v, want the configured size", img.Bounds())
+	}
+}
@@ -0,0 +1,65 @@
+package frontends
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type promConfig struct {
+}
+
+// promEscape escapes a label value for the prometheus exposition format.
+func promEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func (c *promConfig) Setup() {
+}
+
+// Render prints the current conditions in the prometheus text exposition
+// format, e.g. for the textfile collector of the node exporter. The values are
+// always given in base units, so the unit system is ignored.
+func (c *promConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	c.RenderAll([]iface.Data{r}, unitSystem)
+}
+
+// RenderAll prints the current conditions of several locations. The help and
+// type of every metric are printed only once, followed by the samples of all
+// locations, as the exposition format requires.
+func (c *promConfig) RenderAll(rs []iface.Data, unitSystem iface.UnitSystem) {
+	gauge := func(name, help string, value func(iface.Cond) *float32, factor float32) {
+		header := false
+		for _, r := range rs {
+			v := value(r.Current)
+			if v == nil {
+				continue
+			}
+			if !header {
+				fmt.Printf("# HELP %s %s\n", name, help)
+				fmt.Printf("# TYPE %s gauge\n", name)
+				header = true
+			}
+			labels := fmt.Sprintf(`{location="%s",backend="%s"}`, promEscape(r.Location), promEscape(r.Source))
+			fmt.Printf("%s%s %g\n", name, labels, *v*factor)
+		}
+	}
+	percent := func(value *int) *float32 {
+		if value == nil {
+			return nil
+		}
+		f := float32(*value)
+		return &f
+	}
+
+	gauge("wego_temperature_celsius", "Current temperature.", func(cur iface.Cond) *float32 { return cur.TempC }, 1)
+	gauge("wego_feels_like_celsius", "Current felt temperature.", func(cur iface.Cond) *float32 { return cur.FeelsLikeC }, 1)
+	gauge("wego_wind_speed_meters_per_second", "Current average wind speed.", func(cur iface.Cond) *float32 { return cur.WindspeedKmph }, 1/3.6)
+	gauge("wego_humidity_ratio", "Current relative humidity.", func(cur iface.Cond) *float32 { return percent(cur.Humidity) }, 0.01)
+	gauge("wego_chance_of_rain_ratio", "Current probability of rain or snow.", func(cur iface.Cond) *float32 { return percent(cur.ChanceOfRainPercent) }, 0.01)
+}
+
+func init() {
+	iface.AllFrontends["prometheus"] = &promConfig{}
+}
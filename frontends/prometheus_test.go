@@ -0,0 +1,35 @@
+package frontends
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestPromRender(t *testing.T) {
+	c := &promConfig{}
+	got := captureStdout(t, func() { c.Render(testData(), iface.UnitsMetric) })
+	checkGolden(t, "prometheus.golden", got)
+	for _, name := range []string{"wego_temperature_celsius", "wego_feels_like_celsius", "wego_wind_speed_meters_per_second", "wego_humidity_ratio", "wego_chance_of_rain_ratio"} {
+		if !strings.Contains(got, "# TYPE "+name+" gauge\n"+name+`{location="Berlin",backend="forecast.io"} `) {
+			t.Errorf("metric %s is missing", name)
+		}
+	}
+
+	// unknown values are left out together with their help, and labels are
+	// escaped
+	r := testData()
+	r.Location = `"Home"`
+	r.Current.Humidity = nil
+	got = captureStdout(t, func() { c.RenderAll([]iface.Data{r, testData()}, iface.UnitsMetric) })
+	if strings.Count(got, "wego_humidity_ratio{") != 1 {
+		t.Errorf("got output %q, want the humidity only for the location where it is known", got)
+	}
+	if strings.Count(got, "# HELP wego_temperature_celsius") != 1 {
+		t.Error("the help of a metric is repeated for several locations")
+	}
+	if !strings.Contains(got, `wego_temperature_celsius{location="\"Home\"",backend="forecast.io"} 18.5`) {
+		t.Errorf("got output %q, want the quotes in the location escaped", got)
+	}
+}
@@ -0,0 +1,93 @@
+package frontends
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"text/template"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type templateConfig struct {
+	text string
+	file string
+}
+
+// templateNumber returns the value of the numbers and pointers to numbers
+// found in iface.Data. The bool is false for nil pointers and other types.
+func templateNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case *float32:
+		if n != nil {
+			return float64(*n), true
+		}
+	case *int:
+		if n != nil {
+			return float64(*n), true
+		}
+	}
+	return 0, false
+}
+
+// templateFuncs are the helper functions of the template. Missing values stay
+// missing, so they can be checked with if or with.
+var templateFuncs = template.FuncMap{
+	"icon": func(code iface.WeatherCode) string {
+		return emojiCodes[code]
+	},
+	"toF": func(v interface{}) interface{} {
+		if c, ok := templateNumber(v); ok {
+			return c*9/5 + 32
+		}
+		return nil
+	},
+	"toMph": func(v interface{}) interface{} {
+		if kmph, ok := templateNumber(v); ok {
+			return kmph / 1.609344
+		}
+		return nil
+	},
+	"round": func(v interface{}) interface{} {
+		if n, ok := templateNumber(v); ok {
+			return int(math.Round(n))
+		}
+		return nil
+	},
+}
+
+func (c *templateConfig) Setup() {
+	flag.StringVar(&c.text, "template", "{{.Location}}: {{icon .Current.Code}} {{.Current.Desc}}{{with .Current.TempC}} {{round .}} °C{{end}}\n", "template-frontend: the go `TEMPLATE` executed on the weather data, see the README for the fields and functions")
+	flag.StringVar(&c.file, "template-file", "", "template-frontend: read the template from `FILE` instead of -template")
+}
+
+func (c *templateConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	text := c.text
+	if c.file != "" {
+		b, err := ioutil.ReadFile(c.file)
+		if err != nil {
+			log.Fatalf("template-frontend: Unable to read template: %v", err)
+		}
+		text = string(b)
+	}
+
+	tmpl, err := template.New("template").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		log.Fatalf("template-frontend: Invalid template: %v", err)
+	}
+	if err = tmpl.Execute(os.Stdout, r); err != nil {
+		log.Fatalf("template-frontend: Unable to apply template: %v", err)
+	}
+}
+
+func init() {
+	iface.AllFrontends["template"] = &templateConfig{}
+}
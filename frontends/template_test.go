@@ -0,0 +1,35 @@
+package frontends
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestTemplateRender(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want string
+	}{
+		{"{{.Location}}: {{icon .Current.Code}} {{.Current.Desc}}{{with .Current.TempC}} {{round .}} °C{{end}}\n", "Berlin: ⛅️ Partly cloudy 19 °C\n"},
+		{`{{with index .Forecast 0}}{{.Date.Format "Jan 2"}} {{icon .Condition}} {{round .MintempC}}–{{round .MaxtempC}}{{end}}`, "Jun 1 🌦 12–19"},
+		{"{{round (toF .Current.TempC)}} °F {{round (toMph .Current.WindspeedKmph)}} mph", "65 °F 9 mph"},
+		// missing values stay missing
+		{"{{with .Current.PrecipM}}{{.}}{{else}}-{{end}} {{with toF .Current.PrecipM}}{{.}}{{else}}-{{end}}", "- -"},
+	} {
+		c := &templateConfig{text: tc.text}
+		if got := captureStdout(t, func() { c.Render(testData(), iface.UnitsMetric) }); got != tc.want {
+			t.Errorf("template %q: got %q, want %q", tc.text, got, tc.want)
+		}
+	}
+
+	c := &templateConfig{text: "not used", file: filepath.Join(t.TempDir(), "wego.tmpl")}
+	if err := ioutil.WriteFile(c.file, []byte("{{.Source}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := captureStdout(t, func() { c.Render(testData(), iface.UnitsMetric) }); got != "forecast.io" {
+		t.Errorf("got %q, want the template read from the file", got)
+	}
+}
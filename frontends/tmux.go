@@ -0,0 +1,85 @@
+package frontends
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type tmuxConfig struct {
+	format string
+}
+
+// tmuxFields extends the status bar fields by tmux style sequences. They are
+// only part of the output if the format uses them.
+type tmuxFields struct {
+	barFields
+
+	// TempColor sets the foreground to a color depending on the temperature.
+	TempColor string
+
+	// Reset restores the default style.
+	Reset string
+}
+
+func (c *tmuxConfig) tempColor(cond iface.Cond) string {
+	if cond.TempC == nil {
+		return ""
+	}
+	colmap := []struct {
+		maxtemp float32
+		color   int
+	}{
+		{-15, 21}, {-12, 27}, {-9, 33}, {-6, 39}, {-3, 45},
+		{0, 51}, {2, 50}, {4, 49}, {6, 48}, {8, 47},
+		{10, 46}, {13, 82}, {16, 118}, {19, 154}, {22, 190},
+		{25, 226}, {28, 220}, {31, 214}, {34, 208}, {37, 202},
+	}
+
+	col := 196
+	for _, candidate := range colmap {
+		if *cond.TempC < candidate.maxtemp {
+			col = candidate.color
+			break
+		}
+	}
+	return fmt.Sprintf("#[fg=colour%d]", col)
+}
+
+func (c *tmuxConfig) Setup() {
+	flag.StringVar(&c.format, "tmux-format", "{{.Icon}} {{.Temp}}", "tmux-frontend: the go `TEMPLATE` for the status line, fields are Icon, Desc, Temp, FeelsLike, Wind, Rain, AQI, Location, TempColor and Reset")
+}
+
+func (c *tmuxConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	tmpl, err := template.New("tmux").Parse(c.format)
+	if err != nil {
+		log.Fatalf("tmux-frontend: Invalid format: %v", err)
+	}
+
+	fields := tmuxFields{
+		barFields: newBarFields(r.Current, r.Location, unitSystem),
+		TempColor: c.tempColor(r.Current),
+		Reset:     "#[default]",
+	}
+	var out bytes.Buffer
+	if err = tmpl.Execute(&out, fields); err != nil {
+		log.Fatalf("tmux-frontend: Unable to apply format: %v", err)
+	}
+
+	// the status line must be a single line
+	fmt.Println(strings.Replace(out.String(), "\n", " ", -1))
+}
+
+// MaxLocations is one, as tmux shows a single status line.
+func (c *tmuxConfig) MaxLocations() int {
+	return 1
+}
+
+func init() {
+	iface.AllFrontends["tmux"] = &tmuxConfig{}
+}
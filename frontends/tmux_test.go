@@ -0,0 +1,44 @@
+package frontends
+
+import (
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestTmuxRender(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		unit   iface.UnitSystem
+		want   string
+	}{
+		{"{{.Icon}} {{.Temp}}", iface.UnitsMetric, "⛅️ 18°C\n"},
+		{"{{.TempColor}}{{.Temp}}{{.Reset}} {{.Desc}}", iface.UnitsMetric, "#[fg=colour154]18°C#[default] Partly cloudy\n"},
+		{"{{.Location}}: {{.FeelsLike}} {{.Wind}} {{.Rain}}", iface.UnitsImperial, "Berlin: 63°F → 8 mph 20%\n"},
+		// the status line stays on a single line
+		{"{{.Icon}}\n{{.Temp}}", iface.UnitsMetric, "⛅️ 18°C\n"},
+	} {
+		c := &tmuxConfig{format: tc.format}
+		if got := captureStdout(t, func() { c.Render(testData(), tc.unit) }); got != tc.want {
+			t.Errorf("format %q: got %q, want %q", tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestTmuxTempColor(t *testing.T) {
+	c := &tmuxConfig{}
+	for _, tc := range []struct {
+		temp *float32
+		want string
+	}{
+		{nil, ""},
+		{testFloat(-20), "#[fg=colour21]"},
+		{testFloat(0), "#[fg=colour50]"},
+		{testFloat(18.5), "#[fg=colour154]"},
+		{testFloat(40), "#[fg=colour196]"},
+	} {
+		if got := c.tempColor(iface.Cond{TempC: tc.temp}); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package frontends
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type waybarConfig struct {
+	format string
+}
+
+// barFields are the values of a condition which can be used in the format
+// templates of the status bar frontends.
+type barFields struct {
+	Icon      string
+	Desc      string
+	Temp      string
+	FeelsLike string
+	Wind      string
+	Rain      string
+	AQI       string
+	Location  string
+}
+
+func newBarFields(cond iface.Cond, location string, unit iface.UnitSystem) (ret barFields) {
+	ret.Icon = emojiCodes[cond.Code]
+	ret.Desc = cond.Desc
+	ret.Location = location
+
+	if cond.TempC != nil {
+		t, u := unit.Temp(*cond.TempC)
+		ret.Temp = fmt.Sprintf("%d%s", int(t), u)
+	}
+	if cond.FeelsLikeC != nil {
+		t, u := unit.Temp(*cond.FeelsLikeC)
+		ret.FeelsLike = fmt.Sprintf("%d%s", int(t), u)
+	}
+	if cond.WindspeedKmph != nil {
+		s, u := unit.Speed(*cond.WindspeedKmph)
+		ret.Wind = fmt.Sprintf("%d %s", int(s), u)
+		if cond.WinddirDegree != nil {
+			ret.Wind = windArrow(*cond.WinddirDegree) + " " + ret.Wind
+		}
+	}
+	if cond.ChanceOfRainPercent != nil {
+		ret.Rain = fmt.Sprintf("%d%%", *cond.ChanceOfRainPercent)
+	}
+	if cond.AQI != nil {
+		ret.AQI = fmt.Sprintf("%d", *cond.AQI)
+	}
+	return
+}
+
+// waybarClass returns the css class for the weather code, so the module can be
+// styled depending on the weather.
+func waybarClass(code iface.WeatherCode) string {
+	switch code {
+	case iface.CodeSunny:
+		return "clear"
+	case iface.CodePartlyCloudy, iface.CodeCloudy, iface.CodeVeryCloudy:
+		return "cloudy"
+	case iface.CodeFog:
+		return "fog"
+	case iface.CodeLightRain, iface.CodeLightShowers, iface.CodeHeavyRain, iface.CodeHeavyShowers:
+		return "rain"
+	case iface.CodeLightSleet, iface.CodeLightSleetShowers, iface.CodeFreezingRain, iface.CodeHail:
+		return "sleet"
+	case iface.CodeLightSnow, iface.CodeLightSnowShowers, iface.CodeHeavySnow, iface.CodeHeavySnowShowers:
+		return "snow"
+	case iface.CodeThunderyShowers, iface.CodeThunderyHeavyRain, iface.CodeThunderySnowShowers, iface.CodeTornado:
+		return "storm"
+	}
+	return "unknown"
+}
+
+func (c *waybarConfig) Setup() {
+	flag.StringVar(&c.format, "waybar-format", "{{.Icon}} {{.Temp}}", "waybar-frontend: the go `TEMPLATE` for the text, fields are Icon, Desc, Temp, FeelsLike, Wind, Rain, AQI and Location")
+}
+
+func (c *waybarConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+	tmpl, err := template.New("waybar").Parse(c.format)
+	if err != nil {
+		log.Fatalf("waybar-frontend: Invalid format: %v", err)
+	}
+
+	fields := newBarFields(r.Current, r.Location, unitSystem)
+	var text bytes.Buffer
+	if err = tmpl.Execute(&text, fields); err != nil {
+		log.Fatalf("waybar-frontend: Unable to apply format: %v", err)
+	}
+
+	tooltip := []string{r.Location, fields.Icon + " " + fields.Desc}
+	if fields.Temp != "" {
+		tooltip = append(tooltip, "Temperature: "+fields.Temp)
+	}
+	if fields.FeelsLike != "" {
+		tooltip = append(tooltip, "Feels like: "+fields.FeelsLike)
+	}
+	if fields.Wind != "" {
+		tooltip = append(tooltip, "Wind: "+fields.Wind)
+	}
+	if fields.Rain != "" {
+		tooltip = append(tooltip, "Chance of rain: "+fields.Rain)
+	}
+	if r.Current.AQI != nil {
+		tooltip = append(tooltip, fmt.Sprintf("Air quality: %s (%s)", fields.AQI, iface.AQICategory(*r.Current.AQI)))
+	}
+
+	out, err := json.Marshal(struct {
+		Text    string `json:"text"`
+		Tooltip string `json:"tooltip"`
+		Class   string `json:"class"`
+	}{text.String(), strings.Join(tooltip, "\n"), waybarClass(r.Current.Code)})
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Stdout.Write(append(out, '\n'))
+}
+
+// MaxLocations is one, as waybar shows a single module.
+func (c *waybarConfig) MaxLocations() int {
+	return 1
+}
+
+func init() {
+	iface.AllFrontends["waybar"] = &waybarConfig{}
+}
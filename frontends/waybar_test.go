@@ -0,0 +1,46 @@
+package frontends
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestWaybarRender(t *testing.T) {
+	c := &waybarConfig{format: "{{.Icon}} {{.Temp}} {{.Wind}}"}
+	got := captureStdout(t, func() { c.Render(testData(), iface.UnitsMetric) })
+	if !strings.HasSuffix(got, "}\n") || strings.Count(got, "\n") != 1 {
+		t.Errorf("got output %q, want a single line of json", got)
+	}
+
+	var out map[string]string
+	if err := json.Unmarshal([]byte(got), &out); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"text":    "⛅️ 18°C → 14 km/h",
+		"tooltip": "Berlin\n⛅️ Partly cloudy\nTemperature: 18°C\nFeels like: 17°C\nWind: → 14 km/h\nChance of rain: 20%",
+		"class":   "cloudy",
+	}
+	if len(out) != len(want) {
+		t.Errorf("got the keys %v, want text, tooltip and class", out)
+	}
+	for k, v := range want {
+		if out[k] != v {
+			t.Errorf("got %s %q, want %q", k, out[k], v)
+		}
+	}
+
+	// unknown values are left out of the tooltip
+	r := testData()
+	r.Current = iface.Cond{Code: iface.CodeHeavySnow, Desc: "Snow", AQI: testInt(42)}
+	got = captureStdout(t, func() { c.Render(r, iface.UnitsMetric) })
+	if err := json.Unmarshal([]byte(got), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["tooltip"] != "Berlin\n❄️ Snow\nAir quality: 42 (good)" || out["class"] != "snow" {
+		t.Errorf("got tooltip %q and class %q, want only the known values and snow", out["tooltip"], out["class"])
+	}
+}
@@ -0,0 +1,91 @@
+package iface
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type cachedBackend struct {
+	name     string
+	settings string
+	be       Backend
+	ttl      time.Duration
+	dir      string
+}
+
+type cacheEntry struct {
+	Fetched time.Time
+	Data    Data
+}
+
+// Cached wraps the backend registered under name so the results of Fetch are
+// stored in the user cache directory and reused for ttl. Entries older than
+// ttl are removed from the cache. The settings describe the unit system and
+// the configuration of the backend, like the language, so changing them does
+// not return data cached with the old ones.
+func Cached(name string, be Backend, ttl time.Duration, settings string) Backend {
+	ret := &cachedBackend{name: name, settings: settings, be: be, ttl: ttl}
+	if dir, err := os.UserCacheDir(); err == nil {
+		ret.dir = filepath.Join(dir, "wego")
+		ret.evict()
+	}
+	return ret
+}
+
+func (c *cachedBackend) file(location string, numdays int) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%d|%s", c.name, location, numdays, c.settings)))
+	return filepath.Join(c.dir, fmt.Sprintf("data_%x.json", sum))
+}
+
+// evict removes all cache entries older than the ttl.
+func (c *cachedBackend) evict() {
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), "data_") && time.Since(f.ModTime()) > c.ttl {
+			os.Remove(filepath.Join(c.dir, f.Name()))
+		}
+	}
+}
+
+func (c *cachedBackend) Setup() {
+	c.be.Setup()
+}
+
+func (c *cachedBackend) Fetch(ctx context.Context, location string, numdays int) (Data, error) {
+	if c.dir == "" {
+		return c.be.Fetch(ctx, location, numdays)
+	}
+
+	path := c.file(location, numdays)
+	var entry cacheEntry
+	if b, err := ioutil.ReadFile(path); err == nil && json.Unmarshal(b, &entry) == nil && time.Since(entry.Fetched) < c.ttl {
+		return entry.Data, nil
+	}
+
+	ret, err := c.be.Fetch(ctx, location, numdays)
+	if err != nil {
+		return ret, err
+	}
+
+	b, err := json.Marshal(cacheEntry{time.Now(), ret})
+	if err == nil {
+		if err = os.MkdirAll(c.dir, 0755); err == nil {
+			err = ioutil.WriteFile(path, b, 0644)
+		}
+	}
+	if err != nil {
+		log.Printf("Unable to write cache file (%s): %v", path, err)
+	}
+	return ret, nil
+}
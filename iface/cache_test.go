@@ -0,0 +1,54 @@
+package iface
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingBackend returns the location and counts its calls.
+type countingBackend struct {
+	calls int
+}
+
+func (b *countingBackend) Setup() {}
+
+func (b *countingBackend) Fetch(ctx context.Context, location string, numdays int) (Data, error) {
+	b.calls++
+	return Data{Location: location, Source: "counting"}, nil
+}
+
+func TestCached(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	be := &countingBackend{}
+	cached := Cached("counting", be, time.Hour, "units=metric&counting-lang=en")
+	fetch := func(c Backend, location string, numdays int, wantCalls int) {
+		t.Helper()
+		r, err := c.Fetch(context.Background(), location, numdays)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Location != location {
+			t.Errorf("got data for %q, want %q", r.Location, location)
+		}
+		if be.calls != wantCalls {
+			t.Errorf("got %d calls of the backend, want %d", be.calls, wantCalls)
+		}
+	}
+
+	fetch(cached, "here", 3, 1)
+	// the second call is served from the cache
+	fetch(cached, "here", 3, 1)
+	fetch(cached, "there", 3, 2)
+	fetch(cached, "here", 2, 3)
+
+	// other units or backend settings do not use the cached data
+	fetch(Cached("counting", be, time.Hour, "units=imperial&counting-lang=en"), "here", 3, 4)
+	fetch(Cached("counting", be, time.Hour, "units=metric&counting-lang=de"), "here", 3, 5)
+	fetch(Cached("counting", be, time.Hour, "units=metric&counting-lang=en"), "here", 3, 5)
+
+	// expired data is fetched again
+	fetch(Cached("counting", be, time.Nanosecond, "units=metric&counting-lang=en"), "here", 3, 6)
+}
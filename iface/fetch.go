@@ -0,0 +1,175 @@
+package iface
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// the retries and timeout of FetchJSON
+	fetchRetries = 2
+	fetchTimeout = 10 * time.Second
+
+	// longest Retry-After delay we are willing to wait for before retrying
+	fetchMaxRetryAfter = time.Minute
+)
+
+// fetchBackoff is the delay before the first retry, which is doubled for
+// every further one.
+var fetchBackoff = time.Second
+
+// FetchOptions configures FetchBody.
+type FetchOptions struct {
+	// Client sends the requests, HTTPClient if nil.
+	Client *http.Client
+
+	// Header is added to the requests. The User-Agent defaults to the one of
+	// Get.
+	Header http.Header
+
+	// Retries is the number of times failed requests are retried.
+	Retries int
+
+	// Timeout limits every single attempt, 0 means no limit besides the
+	// deadline of the context.
+	Timeout time.Duration
+}
+
+// rateLimitError is returned when the api rejects a request because too many
+// requests were made.
+type rateLimitError struct {
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	if e.retryAfter > 0 {
+		return fmt.Sprintf("rate limited, retry after %v", e.retryAfter)
+	}
+	return "rate limited, retry later"
+}
+
+// retryAfter parses the Retry-After header, which is either a number of
+// seconds or a http date. Missing or invalid values return 0.
+func retryAfter(header string) time.Duration {
+	if s, err := strconv.Atoi(header); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil && time.Until(t) > 0 {
+		return time.Until(t).Round(time.Second)
+	}
+	return 0
+}
+
+// FetchBody requests url and returns the body of the response. Network errors,
+// server errors and rate limits are retried with exponential backoff. The
+// errors do not contain the url, which often contains an api key, so callers
+// should add it redacted.
+func FetchBody(ctx context.Context, url string, opts FetchOptions) ([]byte, error) {
+	if err := CheckDryRun(url); err != nil {
+		return nil, err
+	}
+	backoff := fetchBackoff
+	for attempt := 0; ; attempt++ {
+		body, retry, err := fetchOnce(ctx, url, opts)
+		if err == nil || !retry || attempt >= opts.Retries || ctx.Err() != nil {
+			return body, err
+		}
+
+		// the jitter keeps concurrent requests from retrying in lockstep
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		if rerr, ok := err.(*rateLimitError); ok && rerr.retryAfter > 0 {
+			if rerr.retryAfter > fetchMaxRetryAfter {
+				return nil, err
+			}
+			wait = rerr.retryAfter
+		}
+		Logf(LogInfo, "Retrying in %v: %v", wait, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// fetchOnce requests url a single time. The returned bool reports whether the
+// request may succeed when retried.
+func fetchOnce(ctx context.Context, uri string, opts FetchOptions) ([]byte, bool, error) {
+	actx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		actx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(actx, "GET", uri, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("User-Agent", "wego/"+Version()+" https://github.com/schachmat/wego")
+	for k, v := range opts.Header {
+		req.Header[k] = v
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = HTTPClient
+	}
+	start := time.Now()
+	res, err := client.Do(req)
+	LogRequest(req, res, err, start)
+	if uerr, ok := err.(*url.Error); ok {
+		// the wrapped error repeats the whole url
+		err = uerr.Err
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil, false, ctx.Err()
+	} else if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		timeout := opts.Timeout
+		if timeout == 0 {
+			timeout = client.Timeout
+		}
+		return nil, true, fmt.Errorf("no response within %v", timeout)
+	} else if err != nil {
+		return nil, true, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		return nil, true, &rateLimitError{retryAfter(res.Header.Get("Retry-After"))}
+	} else if res.StatusCode != 200 {
+		return nil, res.StatusCode >= 500, fmt.Errorf("http status %d", res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, true, fmt.Errorf("unable to read response body: %v", err)
+	}
+	return body, false, nil
+}
+
+// FetchJSON requests url with the additional header and decodes the json
+// response into out. Every attempt times out after a few seconds and failed
+// requests are retried a few times.
+func FetchJSON(ctx context.Context, url string, header http.Header, out interface{}) error {
+	shown := RedactURL(url)
+	body, err := FetchBody(ctx, url, FetchOptions{Header: header, Retries: fetchRetries, Timeout: fetchTimeout})
+	if err != nil {
+		return fmt.Errorf("Unable to get (%s): %v", shown, err)
+	}
+
+	Logf(LogDebug, "Response (%s): %s", shown, string(body))
+
+	if err = json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("Unable to unmarshal response (%s): %v\nThe json body is: %s", shown, err, string(body))
+	}
+	return nil
+}
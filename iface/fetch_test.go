@@ -0,0 +1,223 @@
+package iface
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fetchTestServer answers with the status codes in order, and with 200 and the
+// body ok once they are used up. It returns the server and the number of
+// requests it got.
+func fetchTestServer(t *testing.T, codes ...int) (*httptest.Server, *int32) {
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&n, 1)) - 1
+		if i < len(codes) {
+			w.WriteHeader(codes[i])
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+// shortBackoff makes the retries of the test fast.
+func shortBackoff(t *testing.T) {
+	b := fetchBackoff
+	fetchBackoff = time.Millisecond
+	t.Cleanup(func() { fetchBackoff = b })
+}
+
+func TestFetchBodyRetries(t *testing.T) {
+	shortBackoff(t)
+
+	tests := []struct {
+		name    string
+		codes   []int
+		retries int
+		err     string
+		reqs    int32
+	}{
+		{"success", nil, 2, "", 1},
+		{"server error then success", []int{503}, 2, "", 2},
+		{"fails N times before success", []int{500, 502, 503}, 3, "", 4},
+		{"retries used up", []int{500, 502, 503}, 2, "http status 503", 3},
+		{"client error not retried", []int{404}, 2, "http status 404", 1},
+		{"no retries", []int{503}, 0, "http status 503", 1},
+	}
+	for _, tt := range tests {
+		srv, n := fetchTestServer(t, tt.codes...)
+		body, err := FetchBody(context.Background(), srv.URL, FetchOptions{Retries: tt.retries})
+		if tt.err == "" && (err != nil || string(body) != "ok") {
+			t.Errorf("%s: got %q, %v, want ok", tt.name, body, err)
+		} else if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s: got error %v, want %s", tt.name, err, tt.err)
+		}
+		if got := atomic.LoadInt32(n); got != tt.reqs {
+			t.Errorf("%s: got %d requests, want %d", tt.name, got, tt.reqs)
+		}
+	}
+}
+
+func TestFetchBodyNetworkError(t *testing.T) {
+	shortBackoff(t)
+
+	// nothing listens on the port of a closed server
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	if _, err := FetchBody(context.Background(), srv.URL, FetchOptions{Retries: 1}); err == nil {
+		t.Fatal("got no error without a server")
+	} else if strings.Contains(err.Error(), srv.URL) {
+		t.Errorf("got error %q containing the url", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.header); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := retryAfter(date); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("retryAfter(%q) = %v, want about an hour", date, got)
+	}
+}
+
+func TestFetchBodyRateLimit(t *testing.T) {
+	shortBackoff(t)
+
+	// the first request to a path is rate limited with the path as the delay
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		first := !seen[r.URL.Path]
+		seen[r.URL.Path] = true
+		mu.Unlock()
+		if first {
+			w.Header().Set("Retry-After", strings.TrimPrefix(r.URL.Path, "/"))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	// too long delays are not waited for
+	_, err := FetchBody(context.Background(), srv.URL+"/120", FetchOptions{Retries: 2})
+	if err == nil || err.Error() != "rate limited, retry after 2m0s" {
+		t.Errorf("got error %v, want the parsed delay", err)
+	}
+
+	// short delays are used instead of the backoff
+	start := time.Now()
+	if body, err := FetchBody(context.Background(), srv.URL+"/1", FetchOptions{Retries: 2}); err != nil || string(body) != "ok" {
+		t.Errorf("got %q, %v, want ok after the retry", body, err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want the Retry-After delay of 1s", elapsed)
+	}
+}
+
+func TestFetchJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.Write([]byte("{"))
+			return
+		}
+		w.Write([]byte(`{"agent": "` + r.Header.Get("User-Agent") + `", "token": "` + r.Header.Get("X-Token") + `"}`))
+	}))
+	defer srv.Close()
+
+	var out struct {
+		Agent string
+		Token string
+	}
+	header := http.Header{"X-Token": []string{"t"}}
+	if err := FetchJSON(context.Background(), srv.URL+"/?key=secret", header, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.Agent, "wego/"+Version()) {
+		t.Errorf("got User-Agent %q, want the version of wego", out.Agent)
+	}
+	if out.Token != "t" {
+		t.Errorf("got header %q, want the additional one", out.Token)
+	}
+
+	err := FetchJSON(context.Background(), srv.URL+"/broken?key=secret", nil, &out)
+	if err == nil || !strings.Contains(err.Error(), "Unable to unmarshal") {
+		t.Errorf("got error %v, want an unmarshal error", err)
+	} else if strings.Contains(err.Error(), "secret") {
+		t.Errorf("got error %q containing the api key", err)
+	}
+}
+
+func TestFetchBodyTimeout(t *testing.T) {
+	shortBackoff(t)
+
+	var n int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	_, err := FetchBody(context.Background(), srv.URL, FetchOptions{Retries: 1, Timeout: 20 * time.Millisecond})
+	if err == nil || err.Error() != "no response within 20ms" {
+		t.Errorf("got error %v, want a timeout", err)
+	}
+	if got := atomic.LoadInt32(&n); got != 2 {
+		t.Errorf("got %d requests, want every attempt to time out on its own", got)
+	}
+}
+
+func TestFetchBodyCancel(t *testing.T) {
+	var n int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := FetchBody(ctx, srv.URL, FetchOptions{Retries: 2})
+	if err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("FetchBody returned after %v, want it aborted right away", elapsed)
+	}
+	if got := atomic.LoadInt32(&n); got != 1 {
+		t.Errorf("got %d requests, want no retry after the cancellation", got)
+	}
+}
@@ -0,0 +1,145 @@
+package iface
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// geocodeURI is the open-meteo geocoding api used to look up place names.
+	geocodeURI = "https://geocoding-api.open-meteo.com/v1/search?name=%s&count=1&format=json"
+
+	// geocodeCache holds the results of all lookups of this session.
+	geocodeCache = make(map[string]LatLon)
+	geocodeMutex sync.Mutex
+
+	// ipLocateURI returns the approximate location of the caller's ip address.
+	ipLocateURI = "https://ipinfo.io/json"
+
+	latLonRegexp = regexp.MustCompile(`^-?[0-9]*(\.[0-9]+)?,-?[0-9]*(\.[0-9]+)?$`)
+)
+
+// IsLatLon reports whether location is a latitude,longitude pair.
+func IsLatLon(location string) bool {
+	return latLonRegexp.MatchString(location)
+}
+
+// Geocode looks up the coordinates of the place with the given name. The lock
+// is not held during the request, so concurrent lookups do not wait for each
+// other. The same name may be looked up twice at the same time then, which is
+// harmless.
+func Geocode(ctx context.Context, name string) (LatLon, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	geocodeMutex.Lock()
+	ret, ok := geocodeCache[key]
+	geocodeMutex.Unlock()
+	if ok {
+		return ret, nil
+	}
+
+	res, err := Get(ctx, fmt.Sprintf(geocodeURI, url.QueryEscape(strings.TrimSpace(name))))
+	if err != nil {
+		return LatLon{}, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return LatLon{}, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return LatLon{}, fmt.Errorf("geocoding `%s` failed with status %s", name, res.Status)
+	}
+
+	var resp struct {
+		Results []struct {
+			Latitude  float32 `json:"latitude"`
+			Longitude float32 `json:"longitude"`
+		} `json:"results"`
+	}
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return LatLon{}, err
+	}
+	if len(resp.Results) == 0 {
+		return LatLon{}, fmt.Errorf("no place named `%s` found", name)
+	}
+
+	ret = LatLon{Latitude: resp.Results[0].Latitude, Longitude: resp.Results[0].Longitude}
+	geocodeMutex.Lock()
+	geocodeCache[key] = ret
+	geocodeMutex.Unlock()
+	return ret, nil
+}
+
+// ResolveLatLon returns location unchanged if it is a latitude,longitude pair
+// and the coordinates of the place with that name otherwise. It is meant for
+// backends which only support coordinates.
+func ResolveLatLon(ctx context.Context, location string) (string, error) {
+	if IsLatLon(location) {
+		return location, nil
+	}
+	coords, err := Geocode(ctx, location)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%f,%f", coords.Latitude, coords.Longitude), nil
+}
+
+// LocateIP detects the approximate location of this machine from its public ip
+// address. A detected location is cached on disk and reused for ttl.
+func LocateIP(ctx context.Context, ttl time.Duration) (LatLon, error) {
+	var cache struct {
+		Fetched time.Time
+		Loc     LatLon
+	}
+	path := ""
+	if dir, err := os.UserCacheDir(); err == nil {
+		path = filepath.Join(dir, "wego", "location.json")
+		if b, err := ioutil.ReadFile(path); err == nil && json.Unmarshal(b, &cache) == nil && time.Since(cache.Fetched) < ttl {
+			return cache.Loc, nil
+		}
+	}
+
+	res, err := Get(ctx, ipLocateURI)
+	if err != nil {
+		return LatLon{}, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return LatLon{}, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return LatLon{}, fmt.Errorf("ip location lookup failed with status %s", res.Status)
+	}
+
+	var resp struct {
+		Loc string `json:"loc"`
+	}
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return LatLon{}, err
+	}
+	if !IsLatLon(resp.Loc) {
+		return LatLon{}, fmt.Errorf("ip location lookup returned invalid location `%s`", resp.Loc)
+	}
+	if _, err = fmt.Sscanf(resp.Loc, "%f,%f", &cache.Loc.Latitude, &cache.Loc.Longitude); err != nil {
+		return LatLon{}, err
+	}
+
+	if path != "" {
+		cache.Fetched = time.Now()
+		if b, err := json.Marshal(cache); err == nil && os.MkdirAll(filepath.Dir(path), 0755) == nil {
+			ioutil.WriteFile(path, b, 0644)
+		}
+	}
+	return cache.Loc, nil
+}
@@ -0,0 +1,88 @@
+package iface
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// HTTPClient should be used by all backends for their requests. Connections
+// are kept alive and reused, which saves handshakes for backends doing
+// multiple requests and when fetching multiple locations. There is no overall
+// timeout, requests are bounded by the deadline of their context.
+var HTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	},
+}
+
+var (
+	// DryRun makes the backends print the urls of their requests instead of
+	// sending them.
+	DryRun bool
+
+	// ErrDryRun is returned instead of a response in dry-run mode.
+	ErrDryRun = errors.New("dry run, request not sent")
+
+	// keyParamRe matches the query parameters used by the apis for keys.
+	keyParamRe = regexp.MustCompile(`([?&](?:key|apikey|appid|api_key)=)[^&]*`)
+)
+
+// RedactURL masks the api keys in url. The values of the usual key query
+// parameters are masked as well as every occurrence of secrets, for apis
+// having the key somewhere else.
+func RedactURL(url string, secrets ...string) string {
+	url = keyParamRe.ReplaceAllString(url, "${1}REDACTED")
+	for _, s := range secrets {
+		if s != "" {
+			url = strings.Replace(url, s, "REDACTED", -1)
+		}
+	}
+	return url
+}
+
+// CheckDryRun prints the redacted url and returns ErrDryRun in dry-run mode.
+// Backends not using Get must call it before sending a request.
+func CheckDryRun(url string, secrets ...string) error {
+	if !DryRun {
+		return nil
+	}
+	fmt.Println(RedactURL(url, secrets...))
+	return ErrDryRun
+}
+
+// Get requests url with HTTPClient. The request is aborted when ctx is done.
+// The User-Agent tells the version of wego.
+func Get(ctx context.Context, url string) (*http.Response, error) {
+	if err := CheckDryRun(url); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "wego/"+Version()+" https://github.com/schachmat/wego")
+	start := time.Now()
+	res, err := HTTPClient.Do(req)
+	LogRequest(req, res, err, start)
+	if uerr, ok := err.(*neturl.Error); ok {
+		uerr.URL = RedactURL(uerr.URL)
+	}
+	return res, err
+}
@@ -1,7 +1,11 @@
 package iface
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"math"
+	"strconv"
 	"time"
 )
 
@@ -27,8 +31,153 @@ const (
 	CodeThunderyShowers
 	CodeThunderySnowShowers
 	CodeVeryCloudy
+	CodeHail
+	CodeFreezingRain
+	CodeTornado
 )
 
+// codeNames are the names of the weather codes without the Code prefix.
+var codeNames = map[WeatherCode]string{
+	CodeUnknown:             "Unknown",
+	CodeCloudy:              "Cloudy",
+	CodeFog:                 "Fog",
+	CodeHeavyRain:           "HeavyRain",
+	CodeHeavyShowers:        "HeavyShowers",
+	CodeHeavySnow:           "HeavySnow",
+	CodeHeavySnowShowers:    "HeavySnowShowers",
+	CodeLightRain:           "LightRain",
+	CodeLightShowers:        "LightShowers",
+	CodeLightSleet:          "LightSleet",
+	CodeLightSleetShowers:   "LightSleetShowers",
+	CodeLightSnow:           "LightSnow",
+	CodeLightSnowShowers:    "LightSnowShowers",
+	CodePartlyCloudy:        "PartlyCloudy",
+	CodeSunny:               "Sunny",
+	CodeThunderyHeavyRain:   "ThunderyHeavyRain",
+	CodeThunderyShowers:     "ThunderyShowers",
+	CodeThunderySnowShowers: "ThunderySnowShowers",
+	CodeVeryCloudy:          "VeryCloudy",
+	CodeHail:                "Hail",
+	CodeFreezingRain:        "FreezingRain",
+	CodeTornado:             "Tornado",
+}
+
+// ParseWeatherCode returns the weather code with the given Name.
+func ParseWeatherCode(name string) (WeatherCode, error) {
+	for code, n := range codeNames {
+		if n == name {
+			return code, nil
+		}
+	}
+	return CodeUnknown, fmt.Errorf("unknown weather code `%s`", name)
+}
+
+// Name returns the name of the weather code, like HeavyRain for
+// CodeHeavyRain.
+func (c WeatherCode) Name() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("WeatherCode(%d)", int(c))
+}
+
+// codeSeverity ranks the weather codes from harmless to dangerous.
+var codeSeverity = map[WeatherCode]int{
+	CodeUnknown:             0,
+	CodeSunny:               1,
+	CodePartlyCloudy:        2,
+	CodeCloudy:              3,
+	CodeVeryCloudy:          4,
+	CodeFog:                 5,
+	CodeLightShowers:        6,
+	CodeLightRain:           7,
+	CodeLightSleetShowers:   8,
+	CodeLightSleet:          9,
+	CodeLightSnowShowers:    10,
+	CodeLightSnow:           11,
+	CodeHeavyShowers:        12,
+	CodeHeavyRain:           13,
+	CodeFreezingRain:        14,
+	CodeHeavySnowShowers:    15,
+	CodeHeavySnow:           16,
+	CodeHail:                17,
+	CodeThunderyShowers:     18,
+	CodeThunderyHeavyRain:   19,
+	CodeThunderySnowShowers: 20,
+	CodeTornado:             21,
+}
+
+// Severity returns the rank of the weather code, higher values are more
+// severe weather.
+func (c WeatherCode) Severity() int {
+	return codeSeverity[c]
+}
+
+// DominantCode returns the weather code representing all slots. It is the
+// most severe code which applies to at least a quarter of the slots, so short
+// spells of bad weather do not dominate a whole day. If there is no such code,
+// the most frequent one is used.
+func DominantCode(slots []Cond) WeatherCode {
+	count := make(map[WeatherCode]int)
+	for _, s := range slots {
+		count[s.Code]++
+	}
+
+	ret, frequent := CodeUnknown, CodeUnknown
+	found := false
+	for code, n := range count {
+		if n*4 >= len(slots) && (!found || code.Severity() > ret.Severity()) {
+			ret, found = code, true
+		}
+		if n > count[frequent] || (n == count[frequent] && code.Severity() > frequent.Severity()) {
+			frequent = code
+		}
+	}
+	if !found {
+		return frequent
+	}
+	return ret
+}
+
+// SummarizeDay computes the condition and the daily aggregates of day from its
+// Slots. Slots without the respective value are skipped. The precipitation of
+// a slot lasts until the next one, the last slot lasts as long as the one
+// before it.
+func SummarizeDay(day *Day) {
+	day.Condition = DominantCode(day.Slots)
+	day.MaxtempC, day.MintempC, day.PrecipTotalM = nil, nil, nil
+	day.MaxFeelsLikeC, day.MinFeelsLikeC = nil, nil
+	maxMin := func(v *float32, max, min **float32) {
+		if v == nil {
+			return
+		}
+		if *max == nil || *v > **max {
+			hi := *v
+			*max = &hi
+		}
+		if *min == nil || *v < **min {
+			lo := *v
+			*min = &lo
+		}
+	}
+
+	hours := float32(1)
+	for i, slot := range day.Slots {
+		maxMin(slot.TempC, &day.MaxtempC, &day.MintempC)
+		maxMin(slot.FeelsLikeC, &day.MaxFeelsLikeC, &day.MinFeelsLikeC)
+
+		if i+1 < len(day.Slots) {
+			hours = float32(day.Slots[i+1].Time.Sub(slot.Time).Hours())
+		}
+		if slot.PrecipM != nil {
+			if day.PrecipTotalM == nil {
+				day.PrecipTotalM = new(float32)
+			}
+			*day.PrecipTotalM += *slot.PrecipM * hours
+		}
+	}
+}
+
 type Cond struct {
 	// Time is the time, where this weather condition applies.
 	Time time.Time
@@ -75,6 +224,33 @@ type Cond struct {
 
 	// Humidity is the *relative* humidity and must be in [0, 100].
 	Humidity *int
+
+	// DewPointC is the dew point temperature in degrees celsius.
+	DewPointC *float32
+
+	// PressureHPa is the atmospheric pressure at sea level in hectopascals.
+	PressureHPa *float32
+
+	// UVIndex is the ultraviolet index. It must be >= 0.
+	UVIndex *int
+
+	// CloudCoverPercent is the part of the sky covered by clouds. It must be
+	// in the range [0, 100].
+	CloudCoverPercent *int
+
+	// PM25 and PM10 are the concentrations of particulate matter up to 2.5
+	// and 10 micrometers in micrograms per cubic meter.
+	PM25 *float32
+	PM10 *float32
+
+	// AQI is the US EPA air quality index in [0, 500].
+	AQI *int
+
+	// NearestStormDistM is the distance to the nearest storm in meters and
+	// NearestStormBearing the direction towards it in degrees. They are only
+	// known for the current conditions.
+	NearestStormDistM   *float32
+	NearestStormBearing *int
 }
 
 type Astro struct {
@@ -94,6 +270,40 @@ type Day struct {
 
 	// Astronomy contains planetary data.
 	Astronomy Astro
+
+	// Condition is the weather code representing the whole day, see
+	// DominantCode.
+	Condition WeatherCode
+
+	// MaxtempC is the highest temperature of all Slots in degrees celsius.
+	MaxtempC *float32
+
+	// MintempC is the lowest temperature of all Slots in degrees celsius.
+	MintempC *float32
+
+	// MaxFeelsLikeC and MinFeelsLikeC are the highest and lowest apparent
+	// temperatures of all Slots in degrees celsius.
+	MaxFeelsLikeC *float32
+	MinFeelsLikeC *float32
+
+	// PrecipTotalM is the expected amount of precipitation over the whole day
+	// in meters.
+	PrecipTotalM *float32
+
+	// MoonPhase is the fraction of the lunation in [0, 1). 0 is new moon, 0.25
+	// first quarter, 0.5 full moon and 0.75 last quarter.
+	MoonPhase *float32
+}
+
+// MoonPhaseName returns the name of the nearest of the eight moon phases for
+// a MoonPhase value.
+func MoonPhaseName(phase float32) string {
+	names := []string{"New Moon", "Waxing Crescent", "First Quarter", "Waxing Gibbous", "Full Moon", "Waning Gibbous", "Last Quarter", "Waning Crescent"}
+	i := int(math.Floor(float64(phase)*8+0.5)) % 8
+	if i < 0 {
+		i += 8
+	}
+	return names[i]
 }
 
 type LatLon struct {
@@ -101,11 +311,39 @@ type LatLon struct {
 	Longitude float32
 }
 
+type Alert struct {
+	// Title is a short summary of the alert.
+	Title string
+
+	// Description is the detailed text of the alert.
+	Description string
+
+	// Severity tells how severe the alert is, e.g. "advisory", "watch" or
+	// "warning".
+	Severity string
+
+	// Expires is the time when the alert is no longer valid. It is the zero
+	// time if unknown.
+	Expires time.Time
+}
+
 type Data struct {
 	Current  Cond
 	Forecast []Day
 	Location string
 	GeoLoc   *LatLon
+	Alerts   []Alert
+
+	// Nowcast are the conditions of the next hour in short intervals, mostly
+	// with the precipitation only. It is empty if the backend has no nowcast.
+	Nowcast []Cond
+
+	// Source is the name of the backend which provided the data.
+	Source string
+
+	// Attribution is the credit the weather provider requires to be shown
+	// along with its data. It is empty if there is no such requirement.
+	Attribution string
 }
 
 type UnitSystem int
@@ -164,9 +402,190 @@ func (u UnitSystem) Distance(distM float32) (res float32, unit string) {
 	return
 }
 
+// aqiBreakpoint maps the concentration range [cLo, cHi] of a pollutant
+// linearly to the index range [iLo, iHi].
+type aqiBreakpoint struct {
+	cLo, cHi float64
+	iLo, iHi int
+}
+
+var (
+	aqiPM25 = []aqiBreakpoint{
+		{0, 9, 0, 50}, {9.1, 35.4, 51, 100}, {35.5, 55.4, 101, 150},
+		{55.5, 125.4, 151, 200}, {125.5, 225.4, 201, 300}, {225.5, 325.4, 301, 500},
+	}
+	aqiPM10 = []aqiBreakpoint{
+		{0, 54, 0, 50}, {55, 154, 51, 100}, {155, 254, 101, 150},
+		{255, 354, 151, 200}, {355, 424, 201, 300}, {425, 604, 301, 500},
+	}
+)
+
+func aqiIndex(c float64, bps []aqiBreakpoint) int {
+	for _, bp := range bps {
+		if c <= bp.cHi {
+			return int(math.Round(float64(bp.iHi-bp.iLo)/(bp.cHi-bp.cLo)*(c-bp.cLo))) + bp.iLo
+		}
+	}
+	return 500
+}
+
+// AQIFromPM computes the US EPA air quality index from the particulate matter
+// concentrations. Either of them may be nil, the result is nil if both are.
+func AQIFromPM(pm25, pm10 *float32) *int {
+	var ret *int
+	if pm25 != nil && *pm25 >= 0 {
+		// the concentrations are truncated as the EPA specifies
+		i := aqiIndex(math.Floor(float64(*pm25)*10)/10, aqiPM25)
+		ret = &i
+	}
+	if pm10 != nil && *pm10 >= 0 {
+		i := aqiIndex(math.Floor(float64(*pm10)), aqiPM10)
+		if ret == nil || i > *ret {
+			ret = &i
+		}
+	}
+	return ret
+}
+
+// AQICategory returns the name of the US EPA category of the air quality index.
+func AQICategory(aqi int) string {
+	switch {
+	case aqi <= 50:
+		return "good"
+	case aqi <= 100:
+		return "moderate"
+	case aqi <= 150:
+		return "unhealthy for sensitive groups"
+	case aqi <= 200:
+		return "unhealthy"
+	case aqi <= 300:
+		return "very unhealthy"
+	}
+	return "hazardous"
+}
+
+// ApparentTempC computes the temperature it feels like for backends which do
+// not provide it. Above 27 °C the heat index of the US NWS is used if the
+// humidity is known, below 10 °C the wind chill if the wind is known and
+// faster than 4.8 km/h. Otherwise it is the temperature itself. It returns nil
+// if the temperature is unknown.
+func ApparentTempC(tempC *float32, humidity *int, windKmph *float32) *float32 {
+	if tempC == nil {
+		return nil
+	}
+	t := float64(*tempC)
+	ret := t
+	if t >= 27 && humidity != nil {
+		// the Rothfusz regression works in fahrenheit
+		f, rh := t*1.8+32, float64(*humidity)
+		hi := -42.379 + 2.04901523*f + 10.14333127*rh - 0.22475541*f*rh -
+			0.00683783*f*f - 0.05481717*rh*rh + 0.00122874*f*f*rh +
+			0.00085282*f*rh*rh - 0.00000199*f*f*rh*rh
+		ret = (hi - 32) / 1.8
+	} else if t <= 10 && windKmph != nil && *windKmph > 4.8 {
+		v := math.Pow(float64(*windKmph), 0.16)
+		ret = 13.12 + 0.6215*t - 11.37*v + 0.3965*t*v
+	}
+	r := float32(ret)
+	return &r
+}
+
+// beaufortKmph are the lowest wind speeds in km/h of the Beaufort forces 1 to
+// 12, converted from the m/s limits of the WMO.
+var beaufortKmph = []float32{1.8, 5.8, 12.2, 19.8, 28.8, 38.9, 50, 61.9, 74.9, 88.2, 102.6, 117.7}
+
+// Beaufort returns the force on the Beaufort scale of the wind speed. It
+// returns nil if the wind speed is unknown.
+func Beaufort(spdKmph *float32) *int {
+	if spdKmph == nil {
+		return nil
+	}
+	force := 0
+	for force < len(beaufortKmph) && *spdKmph >= beaufortKmph[force] {
+		force++
+	}
+	return &force
+}
+
+// BeaufortName returns the description of the force on the Beaufort scale.
+func BeaufortName(force int) string {
+	names := []string{"calm", "light air", "light breeze", "gentle breeze",
+		"moderate breeze", "fresh breeze", "strong breeze", "near gale", "gale",
+		"strong gale", "storm", "violent storm", "hurricane"}
+	if force < 0 {
+		force = 0
+	} else if force >= len(names) {
+		force = len(names) - 1
+	}
+	return names[force]
+}
+
+// Visibility converts a visibility given in meters to kilometers or miles,
+// which are the usual units for visibility.
+func (u UnitSystem) Visibility(distM float32) (res float32, unit string) {
+	if u == UnitsMetric || u == UnitsSi || u == UnitsMetricMs {
+		return distM / 1000, "km"
+	} else if u == UnitsImperial {
+		return distM / 1609.344, "mi"
+	}
+	log.Fatalln("Unknown unit system:", u)
+	return
+}
+
+// BackendError is an error of the backend registered under the name Backend.
+type BackendError struct {
+	Backend string
+	Err     error
+}
+
+func (e *BackendError) Error() string {
+	return "[" + e.Backend + "] " + e.Err.Error()
+}
+
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
 type Backend interface {
 	Setup()
-	Fetch(location string, numdays int) Data
+	Fetch(ctx context.Context, location string, numdays int) (Data, error)
+}
+
+// ConfigChecker is implemented by backends which need configuration like an
+// api key before they can be used. CheckConfig returns an error telling what
+// is missing.
+type ConfigChecker interface {
+	CheckConfig() error
+}
+
+// CodeMapper is implemented by backends which map the weather conditions of
+// the provider to weather codes with a table. CodeMap returns the table with
+// the conditions of the provider as keys.
+type CodeMapper interface {
+	CodeMap() map[string]WeatherCode
+}
+
+// IntCodeMap converts a table keyed by numeric provider codes for CodeMap.
+func IntCodeMap(m map[int]WeatherCode) map[string]WeatherCode {
+	ret := make(map[string]WeatherCode, len(m))
+	for k, v := range m {
+		ret[strconv.Itoa(k)] = v
+	}
+	return ret
+}
+
+// DefaultLocator is implemented by backends which can be configured with
+// their own default location, which is used instead of the global one.
+// DefaultLocation returns the empty string if none is configured and an error
+// if the configured one is not in a format accepted by the backend.
+type DefaultLocator interface {
+	DefaultLocation() (string, error)
+}
+
+// DaysLimiter is implemented by backends which can only forecast a limited
+// number of days. MaxDays returns that number, including the current day.
+type DaysLimiter interface {
+	MaxDays() int
 }
 
 type Frontend interface {
@@ -174,6 +593,19 @@ type Frontend interface {
 	Render(weather Data, unitSystem UnitSystem)
 }
 
+// MultiRenderer is implemented by frontends which write a single document,
+// like a json file. If several locations are requested, RenderAll is called
+// once with the weather of all of them instead of Render for every location.
+type MultiRenderer interface {
+	RenderAll(weather []Data, unitSystem UnitSystem)
+}
+
+// LocationsLimiter is implemented by frontends which can only show a limited
+// number of locations, like an image or a status bar module.
+type LocationsLimiter interface {
+	MaxLocations() int
+}
+
 var (
 	AllBackends  = make(map[string]Backend)
 	AllFrontends = make(map[string]Frontend)
@@ -0,0 +1,176 @@
+package iface
+
+import (
+	"testing"
+	"time"
+)
+
+func testFloat(f float32) *float32 {
+	return &f
+}
+
+// testSlots returns slots with the given codes one hour apart.
+func testSlots(codes ...WeatherCode) []Cond {
+	start := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	ret := make([]Cond, len(codes))
+	for i, code := range codes {
+		ret[i] = Cond{Time: start.Add(time.Duration(i) * time.Hour), Code: code}
+	}
+	return ret
+}
+
+func TestDominantCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		slots []Cond
+		want  WeatherCode
+	}{
+		{"no slots", nil, CodeUnknown},
+		{"single", testSlots(CodeCloudy), CodeCloudy},
+		{"severe quarter wins", testSlots(CodeSunny, CodeSunny, CodeSunny, CodeThunderyShowers), CodeThunderyShowers},
+		{"short spell ignored", testSlots(CodeSunny, CodeSunny, CodeSunny, CodeSunny, CodeSunny, CodeThunderyShowers), CodeSunny},
+		{"most severe of the frequent", testSlots(CodeLightRain, CodeLightRain, CodeHeavySnow, CodeHeavySnow, CodeSunny), CodeHeavySnow},
+		{"most frequent without a quarter", testSlots(CodeSunny, CodeCloudy, CodeFog, CodeLightShowers, CodeSunny, CodeLightRain, CodeHail, CodePartlyCloudy, CodeVeryCloudy), CodeSunny},
+	}
+	for _, tt := range tests {
+		if got := DominantCode(tt.slots); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got.Name(), tt.want.Name())
+		}
+	}
+}
+
+func TestSummarizeDayCondition(t *testing.T) {
+	day := Day{Slots: testSlots(CodeSunny, CodeHeavyRain, CodeSunny, CodeHeavyRain)}
+	SummarizeDay(&day)
+	if day.Condition != CodeHeavyRain {
+		t.Errorf("got condition %s, want HeavyRain", day.Condition.Name())
+	}
+}
+
+func TestSummarizeDayPrecipitation(t *testing.T) {
+	// 3-hourly slots with the precipitation per hour, the last one lasts as
+	// long as the one before it
+	day := Day{Slots: testSlots(CodeLightRain, CodeLightRain, CodeSunny, CodeLightRain)}
+	for i := range day.Slots {
+		day.Slots[i].Time = day.Slots[0].Time.Add(time.Duration(i*3) * time.Hour)
+	}
+	day.Slots[0].PrecipM = testFloat(0.001)
+	day.Slots[1].PrecipM = testFloat(0.002)
+	day.Slots[3].PrecipM = testFloat(0.0005)
+	SummarizeDay(&day)
+	if day.PrecipTotalM == nil || *day.PrecipTotalM < 0.01049 || *day.PrecipTotalM > 0.01051 {
+		t.Errorf("got precipitation %v, want 0.0105 m", day.PrecipTotalM)
+	}
+
+	day = Day{Slots: testSlots(CodeLightRain)}
+	day.Slots[0].PrecipM = testFloat(0.002)
+	SummarizeDay(&day)
+	if day.PrecipTotalM == nil || *day.PrecipTotalM != 0.002 {
+		t.Errorf("got precipitation %v for a single slot, want 0.002 m for one hour", day.PrecipTotalM)
+	}
+
+	day = Day{Slots: testSlots(CodeSunny, CodeSunny)}
+	SummarizeDay(&day)
+	if day.PrecipTotalM != nil {
+		t.Errorf("got precipitation %v without any in the slots, want it unset", *day.PrecipTotalM)
+	}
+}
+
+func TestSummarizeDayExtremes(t *testing.T) {
+	day := Day{Slots: testSlots(CodeSunny, CodeSunny, CodeSunny, CodeSunny)}
+	for i, v := range []*float32{testFloat(12), nil, testFloat(-3.5), testFloat(25)} {
+		day.Slots[i].TempC = v
+	}
+	for i, v := range []*float32{nil, testFloat(8), testFloat(-7), testFloat(27.5)} {
+		day.Slots[i].FeelsLikeC = v
+	}
+	SummarizeDay(&day)
+	for name, got := range map[string]struct {
+		v    *float32
+		want float32
+	}{
+		"maximum temperature":      {day.MaxtempC, 25},
+		"minimum temperature":      {day.MintempC, -3.5},
+		"maximum felt temperature": {day.MaxFeelsLikeC, 27.5},
+		"minimum felt temperature": {day.MinFeelsLikeC, -7},
+	} {
+		if got.v == nil || *got.v != got.want {
+			t.Errorf("got %s %v, want %v", name, got.v, got.want)
+		}
+	}
+
+	// the extremes do not point into the slots
+	*day.MaxtempC = 0
+	if *day.Slots[3].TempC != 25 {
+		t.Error("changing the maximum changed the slot")
+	}
+
+	day = Day{Slots: testSlots(CodeSunny)}
+	SummarizeDay(&day)
+	if day.MaxFeelsLikeC != nil || day.MinFeelsLikeC != nil {
+		t.Error("got felt temperature extremes without any in the slots")
+	}
+}
+
+func TestApparentTempC(t *testing.T) {
+	humidity := func(h int) *int { return &h }
+
+	// reference values from the heat index table of the NWS and the wind
+	// chill table of Environment Canada
+	tests := []struct {
+		name     string
+		tempC    float32
+		humidity *int
+		wind     *float32
+		want     float32
+	}{
+		{"heat index 90°F 70%", 32.22, humidity(70), nil, 41.1},
+		{"heat index 100°F 40%", 37.78, humidity(40), nil, 42.8},
+		{"wind chill -10°C 20km/h", -10, nil, testFloat(20), -18},
+		{"wind chill 5°C 10km/h", 5, nil, testFloat(10), 3},
+		{"wind chill -20°C 30km/h", -20, nil, testFloat(30), -33},
+		{"mild", 18, humidity(90), testFloat(40), 18},
+		{"calm cold", 0, humidity(50), testFloat(3), 0},
+		{"hot without humidity", 35, nil, testFloat(10), 35},
+	}
+	for _, tt := range tests {
+		got := ApparentTempC(&tt.tempC, tt.humidity, tt.wind)
+		if got == nil || *got < tt.want-0.5 || *got > tt.want+0.5 {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got := ApparentTempC(nil, humidity(50), testFloat(10)); got != nil {
+		t.Errorf("got %v without a temperature, want nil", *got)
+	}
+}
+
+func TestBeaufort(t *testing.T) {
+	tests := []struct {
+		kmph  float32
+		force int
+		name  string
+	}{
+		{0, 0, "calm"},
+		{1.7, 0, "calm"},
+		{1.8, 1, "light air"},
+		{15, 3, "gentle breeze"},
+		{50, 7, "near gale"},
+		{100, 10, "storm"},
+		{117.7, 12, "hurricane"},
+		{250, 12, "hurricane"},
+	}
+	for _, tt := range tests {
+		got := Beaufort(&tt.kmph)
+		if got == nil || *got != tt.force {
+			t.Errorf("Beaufort(%v) = %v, want %d", tt.kmph, got, tt.force)
+		} else if name := BeaufortName(*got); name != tt.name {
+			t.Errorf("BeaufortName(%d) = %q, want %q", *got, name, tt.name)
+		}
+	}
+	if got := Beaufort(nil); got != nil {
+		t.Errorf("Beaufort(nil) = %d, want nil", *got)
+	}
+	if name := BeaufortName(-1); name != "calm" {
+		t.Errorf("BeaufortName(-1) = %q, want calm", name)
+	}
+}
@@ -0,0 +1,61 @@
+package iface
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type LogLevel int
+
+const (
+	LogError LogLevel = iota
+	LogWarn
+	LogInfo
+	LogDebug
+)
+
+var (
+	logLevel  = LogWarn
+	logLevels = map[string]LogLevel{
+		"error": LogError,
+		"warn":  LogWarn,
+		"info":  LogInfo,
+		"debug": LogDebug,
+	}
+)
+
+// SetLogLevel sets the most verbose level which is logged by its name, one of
+// error, warn, info or debug.
+func SetLogLevel(name string) error {
+	level, ok := logLevels[name]
+	if !ok {
+		return fmt.Errorf("unknown log level `%s`, choices are: error, warn, info, debug", name)
+	}
+	logLevel = level
+	return nil
+}
+
+// Logf logs the message if level is enabled. Timing and request information
+// belongs to LogInfo, raw responses to LogDebug.
+func Logf(level LogLevel, format string, v ...interface{}) {
+	if level <= logLevel {
+		log.Printf(format, v...)
+	}
+}
+
+// LogRequest logs the outcome and duration of a request started at start. Only
+// the host is logged, because urls often contain api keys.
+func LogRequest(req *http.Request, res *http.Response, err error, start time.Time) {
+	if uerr, ok := err.(*url.Error); ok {
+		// the wrapped error repeats the whole url
+		err = uerr.Err
+	}
+	if err != nil {
+		Logf(LogInfo, "%s %s failed after %v: %v", req.Method, req.URL.Host, time.Since(start), err)
+	} else {
+		Logf(LogInfo, "%s %s: %s in %v", req.Method, req.URL.Host, res.Status, time.Since(start))
+	}
+}
@@ -0,0 +1,32 @@
+package iface
+
+import (
+	"runtime/debug"
+)
+
+// Version returns the version of the wego module the binary was built from.
+// Binaries built from a checkout report "(devel)".
+func Version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// Commit returns the vcs revision the binary was built from and whether the
+// working tree had uncommitted changes. It is empty if unknown.
+func Commit() (rev string, modified bool) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", false
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	return
+}
@@ -1,32 +1,317 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/schachmat/ingo"
 	_ "github.com/schachmat/wego/backends"
 	_ "github.com/schachmat/wego/frontends"
 	"github.com/schachmat/wego/iface"
 )
 
-func pluginLists() {
+func backendNames() []string {
 	bEnds := make([]string, 0, len(iface.AllBackends))
 	for name := range iface.AllBackends {
 		bEnds = append(bEnds, name)
 	}
 	sort.Strings(bEnds)
+	return bEnds
+}
 
+func frontendNames() []string {
 	fEnds := make([]string, 0, len(iface.AllFrontends))
 	for name := range iface.AllFrontends {
 		fEnds = append(fEnds, name)
 	}
 	sort.Strings(fEnds)
+	return fEnds
+}
+
+// argPassed reports whether one of the flags with the given names was set on
+// the command line as opposed to the config file.
+func argPassed(names ...string) bool {
+	for _, arg := range os.Args[1:] {
+		if arg == "--" {
+			break
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		for _, name := range names {
+			if arg == name || strings.HasPrefix(arg, name+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseAliases parses location aliases given as semicolon separated
+// name=location entries.
+func parseAliases(s string) (map[string]string, error) {
+	ret := make(map[string]string)
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("alias `%s` is not of the form name=location", entry)
+		}
+		ret[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return ret, nil
+}
+
+// aliasFlag holds the location aliases. Every time the flag is set the
+// aliases are added, so they can be given in several flags or in the config
+// file and on the command line.
+type aliasFlag map[string]string
+
+func (a aliasFlag) String() string {
+	entries := make([]string, 0, len(a))
+	for name, loc := range a {
+		entries = append(entries, name+"="+loc)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ";")
+}
+
+func (a aliasFlag) Set(s string) error {
+	m, err := parseAliases(s)
+	for name, loc := range m {
+		a[name] = loc
+	}
+	return err
+}
+
+// setupBackends calls Setup of all backends and returns the flags each of them
+// registered.
+func setupBackends() map[string][]*flag.Flag {
+	ret := make(map[string][]*flag.Flag)
+	for name, be := range iface.AllBackends {
+		known := make(map[string]bool)
+		flag.VisitAll(func(f *flag.Flag) { known[f.Name] = true })
+		be.Setup()
+		flag.VisitAll(func(f *flag.Flag) {
+			if !known[f.Name] {
+				ret[name] = append(ret[name], f)
+			}
+		})
+	}
+	return ret
+}
+
+// cacheSettings describes the unit system and the values of the flags of a
+// backend for the cache, as they change the weather data. VisitAll returns
+// the flags sorted, so the result does not depend on the order of Setup.
+func cacheSettings(units string, flags []*flag.Flag) string {
+	settings := []string{"units=" + units}
+	for _, f := range flags {
+		settings = append(settings, f.Name+"="+f.Value.String())
+	}
+	return strings.Join(settings, "&")
+}
+
+// fetchChain tries the backends in order until one of them returns the weather
+// data for location. Every backend gets its own timeout, so a hanging backend
+// does not use up the time of the fallbacks, 0 means no timeout. Only the
+// cancellation of ctx stops the chain early. The returned error contains the
+// errors of all backends.
+func fetchChain(ctx context.Context, names []string, chain []iface.Backend, location string, numdays int, timeout time.Duration) (iface.Data, error) {
+	var errs []string
+	for i, be := range chain {
+		days := numdays
+		if dl, ok := iface.AllBackends[names[i]].(iface.DaysLimiter); ok && days > dl.MaxDays() {
+			days = dl.MaxDays()
+		}
+		var bctx context.Context
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			bctx, cancel = context.WithTimeout(ctx, timeout)
+		} else {
+			bctx, cancel = context.WithCancel(ctx)
+		}
+		r, err := be.Fetch(bctx, location, days)
+		cancel()
+		if err == nil {
+			if r.Source == "" {
+				r.Source = names[i]
+			}
+			// most backends only provide the slots of the days
+			for d := range r.Forecast {
+				iface.SummarizeDay(&r.Forecast[d])
+			}
+			return r, nil
+		}
+		err = &iface.BackendError{Backend: names[i], Err: err}
+		if len(chain) == 1 {
+			return r, err
+		}
+		errs = append(errs, err.Error())
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return iface.Data{}, fmt.Errorf("all backends failed:\n%s", strings.Join(errs, "\n"))
+}
+
+// fetchAll fetches the weather data for the locations in parallel, but only
+// concurrency locations at a time to go easy on the api. The results and errors
+// are in the order of locations, a failing location does not affect the others.
+func fetchAll(ctx context.Context, names []string, chain []iface.Backend, locations []string, numdays int, timeout time.Duration, concurrency int) ([]iface.Data, []error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]iface.Data, len(locations))
+	errs := make([]error, len(locations))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(locations); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = fetchChain(ctx, names, chain, locations[i], numdays, timeout)
+			}
+		}()
+	}
+	for i := range locations {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results, errs
+}
+
+// listBackends prints all backends and whether they are configured.
+func listBackends() {
+	for _, name := range backendNames() {
+		status := "ok"
+		if cc, ok := iface.AllBackends[name].(iface.ConfigChecker); ok {
+			if err := cc.CheckConfig(); err != nil {
+				status = "not configured: " + strings.SplitN(err.Error(), "\n", 2)[0]
+			}
+		}
+		fmt.Printf("%s\t%s\n", name, status)
+	}
+}
+
+// dumpCodemaps prints the tables of the backends mapping the conditions of the
+// provider to weather codes. Backends without such a table are listed too, so
+// the output shows which ones map the conditions in code.
+func dumpCodemaps() {
+	for _, name := range backendNames() {
+		cm, ok := iface.AllBackends[name].(iface.CodeMapper)
+		if !ok {
+			fmt.Printf("%s: no table\n", name)
+			continue
+		}
+		fmt.Printf("%s:\n", name)
+		table := cm.CodeMap()
+		keys := make([]string, 0, len(table))
+		for k := range table {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			// numeric codes are sorted by their value
+			a, aerr := strconv.Atoi(keys[i])
+			b, berr := strconv.Atoi(keys[j])
+			if aerr == nil && berr == nil {
+				return a < b
+			}
+			return keys[i] < keys[j]
+		})
+		for _, k := range keys {
+			fmt.Printf("\t%s\t%s\n", k, table[k].Name())
+		}
+	}
+}
+
+// tomlConfigPath returns the path of the TOML config file if there is one. It
+// is used instead of the legacy config if $WEGORC has a .toml extension or if
+// $WEGORC is unset and ~/.wegorc.toml exists.
+func tomlConfigPath() string {
+	if rc := os.Getenv("WEGORC"); rc != "" {
+		if filepath.Ext(rc) == ".toml" {
+			return rc
+		}
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(home, ".wegorc.toml")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// setTOML sets the flags for the values in the TOML table. Keys of nested
+// tables are prefixed with the table name, so api-key in the [forecast] table
+// sets -forecast-api-key. A table named like a flag sets it to its entries in
+// the form key=value separated by semicolons, like the [location-aliases]
+// table. Arrays are joined with commas.
+func setTOML(prefix string, table map[string]interface{}) error {
+	for key, val := range table {
+		name := prefix + key
+		switch v := val.(type) {
+		case map[string]interface{}:
+			if flag.Lookup(name) == nil {
+				if err := setTOML(name+"-", v); err != nil {
+					return err
+				}
+				continue
+			}
+			entries := make([]string, 0, len(v))
+			for k, e := range v {
+				entries = append(entries, fmt.Sprintf("%s=%v", k, e))
+			}
+			sort.Strings(entries)
+			val = strings.Join(entries, ";")
+		case []interface{}:
+			elems := make([]string, len(v))
+			for i, e := range v {
+				elems[i] = fmt.Sprint(e)
+			}
+			val = strings.Join(elems, ",")
+		}
+		if flag.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting `%s`", name)
+		}
+		if err := flag.Set(name, fmt.Sprint(val)); err != nil {
+			return fmt.Errorf("invalid value for `%s`: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// parseTOML sets the flags from the TOML config file at path. Flags given on
+// the command line are parsed afterwards and override these values.
+func parseTOML(path string) error {
+	var table map[string]interface{}
+	if _, err := toml.DecodeFile(path, &table); err != nil {
+		return err
+	}
+	return setTOML("", table)
+}
+
+func pluginLists() {
+	bEnds := backendNames()
+	fEnds := frontendNames()
 
 	fmt.Fprintln(os.Stderr, "Available backends:", strings.Join(bEnds, ", "))
 	fmt.Fprintln(os.Stderr, "Available frontends:", strings.Join(fEnds, ", "))
@@ -34,22 +319,36 @@ func pluginLists() {
 
 func main() {
 	// initialize backends and frontends (flags and default config)
-	for _, be := range iface.AllBackends {
-		be.Setup()
-	}
+	backendFlags := setupBackends()
 	for _, fe := range iface.AllFrontends {
 		fe.Setup()
 	}
 
 	// initialize global flags and default config
-	location := flag.String("location", "40.748,-73.985", "`LOCATION` to be queried")
+	location := flag.String("location", "40.748,-73.985", "`LOCATION` to be queried, separate multiple locations with semicolons")
 	flag.StringVar(location, "l", "40.748,-73.985", "`LOCATION` to be queried (shorthand)")
+	autoLocation := flag.Bool("auto-location", false, "Detect the location from the public ip address if none is given on the command line")
+	autoLocationTTL := flag.Duration("auto-location-ttl", 24*time.Hour, "`DURATION` for which a detected location is reused")
+	aliases := make(aliasFlag)
+	flag.Var(aliases, "location-aliases", "Semicolon separated `ALIASES` like home=40.748,-73.985 which can be used as location, repeat the flag to add more")
 	numdays := flag.Int("days", 3, "`NUMBER` of days of weather forecast to be displayed")
 	flag.IntVar(numdays, "d", 3, "`NUMBER` of days of weather forecast to be displayed (shorthand)")
 	unitSystem := flag.String("units", "metric", "`UNITSYSTEM` to use for output.\n    \tChoices are: metric, imperial, si, metric-ms")
 	flag.StringVar(unitSystem, "u", "metric", "`UNITSYSTEM` to use for output. (shorthand)\n    \tChoices are: metric, imperial, si, metric-ms")
 	selectedBackend := flag.String("backend", "forecast.io", "`BACKEND` to be used")
 	flag.StringVar(selectedBackend, "b", "forecast.io", "`BACKEND` to be used (shorthand)")
+	logLevel := flag.String("log-level", "warn", "`LEVEL` of messages to log: error, warn, info or debug")
+	timeout := flag.Duration("timeout", 30*time.Second, "`DURATION` after which fetching the weather data of a location from a backend is aborted, every location and fallback backend gets its own, 0 waits forever")
+	concurrency := flag.Int("concurrency", 4, "Maximum `NUMBER` of locations to fetch at the same time")
+	cacheTTL := flag.Duration("cache-ttl", 0, "Reuse the weather data fetched from the backend for `DURATION`, 0 disables the cache")
+	completion := flag.String("completion", "", "Print the completion script for `SHELL` (bash, zsh or fish), then exit")
+	version := flag.Bool("version", false, "Print the version of wego, then exit")
+	dumpCodemap := flag.Bool("dump-codemap", false, "Print the tables of the backends mapping the conditions of the provider to weather codes, then exit")
+	listB := flag.Bool("list-backends", false, "Print all backends and whether they are configured, then exit")
+	listF := flag.Bool("list-frontends", false, "Print all frontends, then exit")
+	dryRun := flag.Bool("dry-run", false, "Print the urls of the requests with api keys redacted instead of sending them, then exit")
+	noColor := flag.Bool("no-color", false, "Do not use colors in the output, same as setting the NO_COLOR environment variable")
+	fallback := flag.String("backends", "", "Comma separated `BACKENDS` to try in order until one succeeds, overrides -backend")
 	selectedFrontend := flag.String("frontend", "ascii-art-table", "`FRONTEND` to be used")
 	flag.StringVar(selectedFrontend, "f", "ascii-art-table", "`FRONTEND` to be used (shorthand)")
 
@@ -61,40 +360,202 @@ func main() {
 	}
 
 	// read/write config and parse flags
-	if err := ingo.Parse("wego"); err != nil {
+	if path := tomlConfigPath(); path != "" {
+		if err := parseTOML(path); err != nil {
+			log.Fatalf("Error parsing config: %v", err)
+		}
+		flag.Parse()
+	} else if err := ingo.Parse("wego"); err != nil {
 		log.Fatalf("Error parsing config: %v", err)
 	}
 
-	// non-flag shortcut arguments overwrite possible flag arguments
+	if *completion != "" {
+		if err := writeCompletion(os.Stdout, *completion); err != nil {
+			log.Fatalf("Unable to write completion: %v", err)
+		}
+		return
+	}
+
+	if *version {
+		fmt.Println("wego", iface.Version())
+		if rev, modified := iface.Commit(); rev != "" {
+			if modified {
+				rev += " (modified)"
+			}
+			fmt.Println("commit", rev)
+		}
+		fmt.Println(runtime.Version())
+		return
+	}
+
+	if *listB || *listF || *dumpCodemap {
+		if *dumpCodemap {
+			dumpCodemaps()
+		}
+		if *listB {
+			listBackends()
+		}
+		if *listF {
+			for _, name := range frontendNames() {
+				fmt.Println(name)
+			}
+		}
+		return
+	}
+
+	// the backend from the environment takes precedence over the config file,
+	// but not over the command line
+	if env := os.Getenv("WEGO_BACKEND"); env != "" && !argPassed("backend", "b") {
+		*selectedBackend = env
+	}
+
+	// the debug flags of the backends are aliases for the debug log level
+	flag.Visit(func(f *flag.Flag) {
+		if strings.HasSuffix(f.Name, "-debug") && f.Value.String() == "true" {
+			*logLevel = "debug"
+		}
+	})
+	if err := iface.SetLogLevel(*logLevel); err != nil {
+		log.Fatalf("Invalid log level: %v", err)
+	}
+	iface.DryRun = *dryRun
+
+	// requests are aborted on interrupt, the timeout applies to every fetch
+	// on its own, so it does not depend on the number of locations
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// get selected backends
+	names := []string{*selectedBackend}
+	if *fallback != "" {
+		names = strings.Split(*fallback, ",")
+	}
+	chain := make([]iface.Backend, len(names))
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		be, ok := iface.AllBackends[names[i]]
+		if !ok {
+			log.Fatalf("Could not find selected backend \"%s\", available backends: %s", names[i], strings.Join(backendNames(), ", "))
+		}
+		if *cacheTTL > 0 && !*dryRun {
+			be = iface.Cached(names[i], be, *cacheTTL, cacheSettings(*unitSystem, backendFlags[names[i]]))
+		}
+		chain[i] = be
+	}
+
+	// non-flag shortcut arguments overwrite possible flag arguments, every one
+	// of them is a separate location
+	var locations []string
 	for _, arg := range flag.Args() {
 		if v, err := strconv.Atoi(arg); err == nil && len(arg) == 1 {
 			*numdays = v
 		} else {
-			*location = arg
+			locations = append(locations, arg)
+		}
+	}
+	if *numdays < 0 {
+		log.Fatalf("Invalid number of days %d, it must not be negative", *numdays)
+	}
+	// larger numbers are clamped for every backend of the chain
+	for _, name := range names {
+		if dl, ok := iface.AllBackends[name].(iface.DaysLimiter); ok && *numdays > dl.MaxDays() {
+			log.Printf("Backend \"%s\" forecasts at most %d days, showing %d instead of %d", name, dl.MaxDays(), dl.MaxDays(), *numdays)
+		}
+	}
+	// the default location of the first backend overrides the global one,
+	// but not locations given on the command line
+	if dl, ok := iface.AllBackends[names[0]].(iface.DefaultLocator); ok && len(locations) == 0 && !argPassed("location", "l") {
+		loc, err := dl.DefaultLocation()
+		if err != nil {
+			log.Fatalf("Invalid default location of backend \"%s\": %v", names[0], err)
+		} else if loc != "" {
+			locations = append(locations, loc)
+		}
+	}
+	if len(locations) == 0 && *autoLocation && !argPassed("location", "l") {
+		lctx, cancel := ctx, context.CancelFunc(func() {})
+		if *timeout > 0 {
+			lctx, cancel = context.WithTimeout(ctx, *timeout)
+		}
+		loc, err := iface.LocateIP(lctx, *autoLocationTTL)
+		cancel()
+		if err != nil {
+			log.Printf("Could not detect the location, using \"%s\" instead: %v", *location, err)
+		} else {
+			locations = append(locations, fmt.Sprintf("%f,%f", loc.Latitude, loc.Longitude))
+		}
+	}
+	if len(locations) == 0 {
+		// locations in the flag are separated by semicolons, as commas are
+		// already used in latitude,longitude pairs
+		for _, l := range strings.Split(*location, ";") {
+			if l = strings.TrimSpace(l); l != "" {
+				locations = append(locations, l)
+			}
 		}
 	}
 
-	// get selected backend and fetch the weather data from it
-	be, ok := iface.AllBackends[*selectedBackend]
-	if !ok {
-		log.Fatalf("Could not find selected backend \"%s\"", *selectedBackend)
+	// replace aliases by the locations they stand for
+	for i, l := range locations {
+		if a, ok := aliases[l]; ok {
+			locations[i] = a
+		}
 	}
-	r := be.Fetch(*location, *numdays)
 
 	// set unit system
-	unit := iface.UnitsMetric
-	if *unitSystem == "imperial" {
+	var unit iface.UnitSystem
+	switch *unitSystem {
+	case "metric":
+		unit = iface.UnitsMetric
+	case "imperial":
 		unit = iface.UnitsImperial
-	} else if *unitSystem == "si" {
+	case "si":
 		unit = iface.UnitsSi
-	} else if *unitSystem == "metric-ms" {
+	case "metric-ms":
 		unit = iface.UnitsMetricMs
+	default:
+		log.Fatalf("Unknown unit system \"%s\", choices are: metric, imperial, si, metric-ms", *unitSystem)
 	}
 
-	// get selected frontend and render the weather data with it
+	// frontends leave out colors if NO_COLOR is set
+	if *noColor {
+		os.Setenv("NO_COLOR", "1")
+	}
+
+	// get selected frontend
 	fe, ok := iface.AllFrontends[*selectedFrontend]
 	if !ok {
 		log.Fatalf("Could not find selected frontend \"%s\"", *selectedFrontend)
 	}
-	fe.Render(r, unit)
+	if ll, ok := fe.(iface.LocationsLimiter); ok && len(locations) > ll.MaxLocations() {
+		log.Fatalf("Frontend \"%s\" can show at most %d location(s), but %d were given", *selectedFrontend, ll.MaxLocations(), len(locations))
+	}
+
+	results, errs := fetchAll(ctx, names, chain, locations, *numdays, *timeout, *concurrency)
+	if *dryRun {
+		return
+	}
+
+	// render the weather data of the locations in order, skipping failed ones
+	var fetched []iface.Data
+	for i, r := range results {
+		if errs[i] != nil {
+			log.Printf("Error fetching weather data for \"%s\": %v", locations[i], errs[i])
+			continue
+		}
+		fetched = append(fetched, r)
+	}
+	switch mr, ok := fe.(iface.MultiRenderer); {
+	case len(fetched) == 0:
+	case ok && len(locations) > 1:
+		// these frontends write one document for all locations
+		mr.RenderAll(fetched, unit)
+	default:
+		for _, r := range fetched {
+			fe.Render(r, unit)
+		}
+	}
+	if len(fetched) < len(results) {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// stubBackend returns the result of fetch or, if it is nil, waits until the
+// context is done. It counts its calls.
+type stubBackend struct {
+	fetch func(ctx context.Context, location string) (iface.Data, error)
+	calls int32
+}
+
+func (b *stubBackend) Setup() {}
+
+func (b *stubBackend) Fetch(ctx context.Context, location string, numdays int) (iface.Data, error) {
+	atomic.AddInt32(&b.calls, 1)
+	if b.fetch == nil {
+		<-ctx.Done()
+		return iface.Data{}, ctx.Err()
+	}
+	return b.fetch(ctx, location)
+}
+
+func TestFetchChainCancel(t *testing.T) {
+	hanging, next := &stubBackend{}, &stubBackend{fetch: func(context.Context, string) (iface.Data, error) {
+		return iface.Data{}, nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := fetchChain(ctx, []string{"hanging", "next"}, []iface.Backend{hanging, next}, "here", 1, 0)
+	if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("got error %v, want the cancellation", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("fetchChain returned after %v, want it aborted right away", elapsed)
+	}
+	if next.calls != 0 {
+		t.Error("the next backend was tried after the cancellation")
+	}
+}
+
+// TestFetchChainTimeout checks that a backend waiting for a slow server is
+// aborted after the timeout, and that the fallback gets a timeout of its own.
+func TestFetchChainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	slow := func() *stubBackend {
+		return &stubBackend{fetch: func(ctx context.Context, location string) (iface.Data, error) {
+			_, err := iface.FetchBody(ctx, srv.URL, iface.FetchOptions{})
+			return iface.Data{}, err
+		}}
+	}
+
+	start := time.Now()
+	_, err := fetchChain(context.Background(), []string{"slow"}, []iface.Backend{slow()}, "here", 1, 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("got error %v, want the timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("fetchChain returned after %v, want it aborted after the timeout", elapsed)
+	}
+
+	// the second backend waits for the full timeout as well
+	first, second := slow(), slow()
+	start = time.Now()
+	_, err = fetchChain(context.Background(), []string{"first", "second"}, []iface.Backend{first, second}, "here", 1, 50*time.Millisecond)
+	if err == nil || strings.Count(err.Error(), context.DeadlineExceeded.Error()) != 2 {
+		t.Errorf("got error %v, want both backends to time out", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("fetchChain returned after %v, want a timeout of 50ms for every backend", elapsed)
+	}
+}
+
+func TestFetchChainFallback(t *testing.T) {
+	failing := &stubBackend{fetch: func(context.Context, string) (iface.Data, error) {
+		return iface.Data{}, errors.New("no data")
+	}}
+	working := &stubBackend{fetch: func(ctx context.Context, location string) (iface.Data, error) {
+		return iface.Data{Location: location, Forecast: []iface.Day{{Slots: []iface.Cond{{Code: iface.CodeCloudy}}}}}, nil
+	}}
+	unused := &stubBackend{fetch: working.fetch}
+
+	r, err := fetchChain(context.Background(), []string{"failing", "working", "unused"}, []iface.Backend{failing, working, unused}, "here", 1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Source != "working" || r.Location != "here" {
+		t.Errorf("got data from %q for %q, want it from the working backend for here", r.Source, r.Location)
+	}
+	if failing.calls != 1 || working.calls != 1 || unused.calls != 0 {
+		t.Errorf("got %d, %d and %d calls, want the chain to stop after the working backend", failing.calls, working.calls, unused.calls)
+	}
+	if r.Forecast[0].Condition != iface.CodeCloudy {
+		t.Errorf("got condition %s, want the days summarized", r.Forecast[0].Condition.Name())
+	}
+
+	_, err = fetchChain(context.Background(), []string{"failing", "failing too"}, []iface.Backend{failing, failing}, "here", 1, time.Second)
+	if err == nil {
+		t.Fatal("got no error when all backends fail")
+	}
+	for _, want := range []string{"[failing] no data", "[failing too] no data"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %q, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	be := &stubBackend{fetch: func(ctx context.Context, location string) (iface.Data, error) {
+		if location == "nowhere" {
+			return iface.Data{}, errors.New("unknown location")
+		}
+		return iface.Data{Location: location}, nil
+	}}
+
+	locations := []string{"here", "nowhere", "there"}
+	results, errs := fetchAll(context.Background(), []string{"stub"}, []iface.Backend{be}, locations, 1, time.Second, 2)
+	if len(results) != 3 || len(errs) != 3 {
+		t.Fatalf("got %d results and %d errors, want one of each for every location", len(results), len(errs))
+	}
+	if errs[0] != nil || errs[2] != nil || results[0].Location != "here" || results[2].Location != "there" {
+		t.Errorf("got %v and %v, want the working locations in order", results, errs)
+	}
+	if errs[1] == nil || !strings.Contains(errs[1].Error(), "unknown location") {
+		t.Errorf("got error %v for the failing location", errs[1])
+	}
+	if be.calls != 3 {
+		t.Errorf("got %d calls, want every location fetched once", be.calls)
+	}
+}